@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+)
+
+/*
+api.go serves the node's HTTP API on the address of api.address, or
+--api-addr: /metrics, for Prometheus to scrape, and the block explorer at
+/ with the JSON endpoints under /api/ it reads (see explorer.go).
+*/
+
+func (n *Node) newAPIHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", n.handleMetrics)
+	n.registerExplorer(mux)
+	return mux
+}
+
+func (n *Node) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := n.metrics.writeTo(w); err != nil {
+		apiLog.Debug("unable to write metrics", "remote", r.RemoteAddr, "err", err)
+	}
+}
+
+// starts serving the API, returning the server so it can be shut down with the node
+func (n *Node) startAPI(address string) (*http.Server, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	server := &http.Server{Handler: n.newAPIHandler()}
+	go func() {
+		if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+			apiLog.Error("API server stopped", "err", err)
+		}
+	}()
+	apiLog.Info("serving API", "address", listener.Addr().String())
+	return server, nil
+}
+
+func stopAPI(ctx context.Context, server *http.Server) {
+	if err := server.Shutdown(ctx); err != nil {
+		apiLog.Warn("API server did not shut down cleanly", "err", err)
+	}
+}
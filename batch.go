@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+/*
+batch.go anchors many documents with a single packet.  The document
+hashes are put in a merkle tree, only its root is signed and uploaded,
+and every document keeps the proof linking it to the root.  Together
+with the proof linking the root's packet to its block (see proof.go)
+this shows the document was anchored, without the other documents of
+the batch ever being published.
+*/
+
+const batchContentType = "application/vnd.go-blockchain.batch+json"
+
+type Batch struct {
+	Root      []byte
+	Size      uint64
+	Documents []BatchEntry
+}
+
+type BatchEntry struct {
+	Path         string
+	DocumentHash []byte
+	Proof        []MerkleStep // from the document hash to the batch root
+}
+
+func batchLeafHash(documentHash []byte) []byte {
+	return merkleLeafHash(documentHash)
+}
+
+// reads a list of file names, one per line, ignoring blank lines and lines starting with #
+func readBatchList(filePath string) ([]string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	paths   := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			paths = append(paths, line)
+		}
+	}
+	return paths, scanner.Err()
+}
+
+// hashes each document, in the given order, and builds the batch over them
+func buildBatch(paths []string, progress io.Writer) (*Batch, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("batch has no documents")
+	}
+	batch  := &Batch{Documents: []BatchEntry{}}
+	leaves := [][]byte{}
+	for _, path := range paths {
+		documentHash, size, err := hashFile(path, defaultHashAlgorithm, progress)
+		if err != nil {
+			return nil, err
+		}
+		batch.Documents = append(batch.Documents, BatchEntry{Path: path, DocumentHash: documentHash})
+		batch.Size      = batch.Size + size
+		leaves          = append(leaves, batchLeafHash(documentHash))
+	}
+
+	batch.Root = merkleRoot(leaves)
+	for i := range batch.Documents {
+		batch.Documents[i].Proof = merkleProof(leaves, i)
+	}
+	return batch, nil
+}
+
+func (batch *Batch) findDocument(documentHash []byte) (BatchEntry, bool) {
+	for _, entry := range batch.Documents {
+		if string(entry.DocumentHash) == string(documentHash) {
+			return entry, true
+		}
+	}
+	return BatchEntry{}, false
+}
+
+func verifyBatchEntry(entry BatchEntry, root []byte) bool {
+	return verifyMerkleProof(batchLeafHash(entry.DocumentHash), entry.Proof, root)
+}
+
+func createBatchPacket(batch *Batch, keys Keypair, memo string) Packet {
+	packet := Packet{Hash:          batch.Root,
+	                 Owner:         keys.Public,
+	                 HashAlgorithm: defaultHashAlgorithm,
+	                 ContentType:   batchContentType,
+	                 Size:          batch.Size,
+	                 Memo:          memo}
+	packet.Signature = signHash(packetSigningHash(packet), keys)
+	return packet
+}
+
+func writeBatch(batch *Batch, filePath string) error {
+	data, err := json.MarshalIndent(batch, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, data, 0644)
+}
+
+func readBatch(filePath string) (*Batch, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	batch := &Batch{}
+	if err := json.Unmarshal(data, batch); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
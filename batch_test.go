@@ -0,0 +1,73 @@
+package main
+
+import(
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func createTestBatch(t *testing.T) (*Batch, []string) {
+	dir   := t.TempDir()
+	paths := []string{}
+	for _, name := range []string{"a.bin", "b.bin", "c.bin", "d.bin", "e.bin"} {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte("artifact "+name), 0644)
+		paths = append(paths, path)
+	}
+	batch, err := buildBatch(paths, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return batch, paths
+}
+
+func TestBuildBatch(t *testing.T){
+	batch, paths := createTestBatch(t)
+	if len(batch.Documents) != len(paths) {
+		t.Fatalf("batch has %v documents, expected %v", len(batch.Documents), len(paths))
+	}
+
+	for _, entry := range batch.Documents {
+		if document, _ := os.ReadFile(entry.Path); string(entry.DocumentHash) != string(hashDocument(document)) {
+			t.Errorf("wrong hash for %v", entry.Path)
+		}
+		if !verifyBatchEntry(entry, batch.Root) {
+			t.Errorf("fails to verify %v", entry.Path)
+		}
+	}
+
+	// a document outside the batch can not borrow another's proof
+	outsider := batch.Documents[0]
+	outsider.DocumentHash = hashDocument([]byte("not in the batch"))
+	if verifyBatchEntry(outsider, batch.Root) {
+		t.Error("verifies document that is not in the batch")
+	}
+
+	if _, err := buildBatch([]string{}, nil); err == nil {
+		t.Error("builds an empty batch")
+	}
+}
+
+func TestReadBatchList(t *testing.T){
+	listPath := filepath.Join(t.TempDir(), "artifacts.txt")
+	os.WriteFile(listPath, []byte("# build 42\nfirst.bin\n\n  second.bin  \n"), 0644)
+
+	paths, err := readBatchList(listPath)
+	if err != nil || len(paths) != 2 || paths[0] != "first.bin" || paths[1] != "second.bin" {
+		t.Errorf("reads wrong paths %v", paths)
+	}
+
+	batch, _ := createTestBatch(t)
+	batchPath := filepath.Join(t.TempDir(), "artifacts.txt.batch.json")
+	if err := writeBatch(batch, batchPath); err != nil {
+		t.Fatal(err)
+	}
+	read, err := readBatch(batchPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, found := read.findDocument(batch.Documents[3].DocumentHash)
+	if !found || !verifyBatchEntry(entry, read.Root) {
+		t.Error("fails to verify entry of a batch read from disk")
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"crypto/sha256"
 	"encoding/binary"
+	"errors"
 	"fmt"
 
 	// "github.com/nvonpentz/go-hashable-keys"
@@ -14,6 +15,9 @@ type Block struct {
 	PrevHash []byte
 	Data     []Packet
 	Hash     []byte
+	Records  []Record // typed records, see record.go
+	Signer    []byte   // the authority that sealed the block, under proof of authority (see poa.go)
+	Signature []byte   // the signer's signature of Hash
 }
 
 type BlockWrapper struct {
@@ -46,6 +50,20 @@ func createGenesisBlock() Block {
 var genesisBlock = createGenesisBlock()
 
 func (block *Block) calcHashForBlock(nonce uint32) []byte {
+	// hash the block data
+	blockPacketsHash := hashPacketList(block.Data)
+	blockRecordsHash := []byte{}
+	if len(block.Records) != 0 {
+		blockRecordsHash = hashRecordList(block.Records)
+	}
+
+	return hashBlockHeader(block.Index, block.PrevHash, blockPacketsHash, blockRecordsHash, nonce, block.Signer)
+}
+
+// hashes a block from the roots of its contents, which is all an inclusion proof needs.
+// The signer of a sealed block is hashed too, as its weight and who may seal
+// the blocks after it depend on the signer; blocks without one hash as before
+func hashBlockHeader(index uint32, prevHash, packetsHash, recordsHash []byte, nonce uint32, signer []byte) []byte {
 	h := sha256.New()
 
 	// convert nonce to bytes
@@ -54,66 +72,83 @@ func (block *Block) calcHashForBlock(nonce uint32) []byte {
 
 	// convert block index to bytes
 	blockIndex := make([]byte, 4)
-	binary.LittleEndian.PutUint32(blockIndex, block.Index)
-
-	// hash the block data
-	blockPacketsHash := hashPacketList(block.Data)
+	binary.LittleEndian.PutUint32(blockIndex, index)
 
 	h.Write(blockIndex)
-	h.Write(block.PrevHash)
-	h.Write(blockPacketsHash)
+	h.Write(prevHash)
+	h.Write(packetsHash)
+	h.Write(recordsHash)
 	h.Write(nonceBytes)
-	
+	if len(signer) != 0 {
+		h.Write(signer)
+	}
+
 	return h.Sum(nil)
 }
 
 func (oldBlock *Block) isValidNextBlock(newBlock *Block) (bool){
+	if err := validateNextBlock(oldBlock, newBlock); err != nil {
+		chainLog.Debug("block invalid", "index", newBlock.Index, "err", err)
+		return false
+	}
+	return true
+}
+
+// why block can not follow prev, or nil if it can; checkchain prints the error
+func validateNextBlock(prev, block *Block) error {
 	// new block's index must be one greater
-	isValidIndex := newBlock.Index == oldBlock.Index + 1
-	// fmt.Printf("isValidIndex %v \n", isValidIndex)
+	if block.Index != prev.Index+1 {
+		return fmt.Errorf("has index %v, but follows block %v", block.Index, prev.Index)
+	}
 
 	// new block's previous hash has to equal the hash of the old block
-	isValidPrevHash := string(newBlock.PrevHash) == string(oldBlock.Hash)
-	// fmt.Printf("isValidPrevHash %v \n", isValidPrevHash)
+	if string(block.PrevHash) != string(prev.Hash) {
+		return fmt.Errorf("does not link to the block before it, its previous hash is %v instead of %v", formatHash(block.PrevHash), formatHash(prev.Hash))
+	}
 
 	// all packets in block data must be valid
-	areValidPacketSignatures := verifyPacketList(newBlock.Data)
-	// fmt.Printf("areValidPacketSignatures %v \n", areValidPacketSignatures)
-
-	// hash value must be below difficulty
-	var newBlockHashAsInt uint32
-	if len(newBlock.Hash) == 0 {
-		fmt.Println(len(newBlock.Hash))
-		fmt.Println("No hash, block invalid")
-		return false
-	} else {
-		newBlockHashAsInt = binary.LittleEndian.Uint32(newBlock.Hash)		
+	for _, packet := range block.Data {
+		if !verifyPacket(packet) {
+			return fmt.Errorf("holds packet %v, whose signature or metadata is invalid", formatHash(packet.Hash))
+		}
 	}
 
-	isHashBelowDifficulty := newBlockHashAsInt < difficulty
-	// fmt.Printf("newBlockHashAsInt %v, difficulty %v", newBlockHashAsInt, difficulty)
-	// fmt.Printf("isHashBelowDifficulty %v \n", isHashBelowDifficulty)
-
+	// all records must be of a known type and pass that type's validation
+	for _, record := range block.Records {
+		if !verifyRecord(record, block) {
+			rt, known := recordTypes[record.Type]
+			if !known {
+				return fmt.Errorf("holds a record of unknown type %v", record.Type)
+			}
+			return fmt.Errorf("holds an invalid %v record %v", rt.name, formatHash(hashRecord(record)))
+		}
+	}
 
 	// hash of entire block must equal the claimed block hash
-	calculatedBlockHash := newBlock.calcHashForBlock(newBlock.Nonce)
-	isCorrectBlockHash  := string(calculatedBlockHash) == string(newBlock.Hash)
-	// fmt.Printf("isCorrectBlockHash %v \n", isCorrectBlockHash)
-
-	isValidBlock := isValidIndex &&
-					isValidPrevHash &&
-					areValidPacketSignatures &&
-					isHashBelowDifficulty &&
-					isCorrectBlockHash
-
-	//this is where proof of work comes to validate the calculated hash
-	return isValidBlock
+	if len(block.Hash) == 0 {
+		return errors.New("has no hash")
+	}
+	if calculated := block.calcHashForBlock(block.Nonce); string(calculated) != string(block.Hash) {
+		return fmt.Errorf("has hash %v, but its contents hash to %v", formatHash(block.Hash), formatHash(calculated))
+	}
+
+	// block must be sealed, eg. its hash below difficulty, see consensus.go
+	if !consensus.verifyHeader(block) {
+		switch consensus.(type) {
+		case powEngine:
+			return fmt.Errorf("is not mined, its hash is not below the difficulty %v", difficulty)
+		case poaEngine:
+			return errors.New("is not sealed, it is not signed by its signer")
+		default:
+			return errors.New("is not sealed")
+		}
+	}
+	return nil
 }
 
 func printSeenBlockWrapper(seenBlocks map[string]bool){
-    for blockHashString, _  := range seenBlocks{
-        blockHashBytes := []byte(blockHashString)
-        fmt.Printf("  %v\n", blockHashBytes)
+    for _, blockHash := range seenBlockHashes(seenBlocks) {
+        fmt.Printf("  %v\n", blockHash)
     }
 }
 
@@ -1,6 +1,7 @@
 package main
 
 import(
+	"os"
 	"testing"
 	// "fmt"
 )
@@ -15,7 +16,7 @@ func areEqualBlocks(b1 Block, b2 Block) bool {
 }
 
 func TestIsValidNextBlock(t *testing.T){
-	difficulty = 4294967295 // all hashses pass
+	useDifficulty(t, 4294967295) // all hashes pass
 
 	// create 3 different valid packets
 	keys01 := GenerateNewKeypair()
@@ -69,7 +70,7 @@ func TestIsValidNextBlock(t *testing.T){
 
 	// test block with invalid data
 	// create invalid packet
-	doc       := readDocument("document.txt")
+	doc, _    := os.ReadFile("document.txt")
 	hashedDoc := hashDocument(doc)
 	signature := signHash(hashedDoc, *keys01) // sign with keys01
 	packet04  := Packet{Hash: hashedDoc, Signature: signature, Owner: keys02.Public}
@@ -1,22 +1,23 @@
 package main 
 
-import "fmt"
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+)
 
 type Blockchain struct {
 	Blocks []Block
 }
 
 func (b Blockchain) printBlockchain(){
-    for i := range b.Blocks {
-        block := b.Blocks[i]
-        fmt.Printf("  Block %d is: \n   PrevHash: %v \n   Data:     %v \n   Hash:     %v \n", i, block.PrevHash, block.Data, block.Hash)
-    }
+    fmt.Print(formatBlockchain(b))
 }
 
 func (blockchain *Blockchain) addBlock(block Block) {
 	lastBlock := blockchain.getLastBlock()
 
-	if lastBlock.isValidNextBlock(&block) == true {
+	if lastBlock.isValidNextBlock(&block) == true && blockchain.hasValidAuthority(&block) {
 		blockchain.Blocks = append(blockchain.Blocks, block)
 	} else {
 	}
@@ -34,7 +35,27 @@ func (blockchain *Blockchain) isValidChain() bool {
 		} else{
 		}
 	}
-	return true
+	return blockchain.hasValidAuthorities()
+}
+
+// whether a block of the blockchain already holds the packet
+func (blockchain Blockchain) hasPacket(packet Packet) bool {
+	for _, block := range blockchain.Blocks {
+		if packetListHasPacket(blockPackets(block), packet) {
+			return true
+		}
+	}
+	return false
+}
+
+// whether a block of the blockchain already holds the record
+func (blockchain Blockchain) hasRecord(record Record) bool {
+	for _, block := range blockchain.Blocks {
+		if recordListHasRecord(block.Records, record) {
+			return true
+		}
+	}
+	return false
 }
 
 func (blockchain Blockchain) getLastBlock() Block{
@@ -42,12 +63,32 @@ func (blockchain Blockchain) getLastBlock() Block{
 	return lastBlock
 }
 
+// the block with the given index, or hash in hex
+func (blockchain Blockchain) findBlock(id string) (Block, bool) {
+	if index, err := strconv.ParseUint(id, 10, 32); err == nil { // too short to be a hash
+		if index < uint64(len(blockchain.Blocks)) {
+			return blockchain.Blocks[index], true
+		}
+		return Block{}, false
+	}
+	hash, err := hex.DecodeString(id)
+	if err != nil {
+		return Block{}, false
+	}
+	for _, block := range blockchain.Blocks {
+		if string(block.Hash) == string(hash) {
+			return block, true
+		}
+	}
+	return Block{}, false
+}
+
 // recursively searches through the blocks for a packet with a given hash
 func (blockchain Blockchain) findPacketByHashAndPublicKey(packetHash, publicKey []byte) Packet {
 	lastBlock := blockchain.getLastBlock()
 	
-	if packetListHasPacketHashAndPublicKey(lastBlock.Data, packetHash, publicKey){
-		packet := getPacketFromListByHashAndPublicKey(lastBlock.Data, packetHash, publicKey)
+	if packetListHasPacketHashAndPublicKey(blockPackets(lastBlock), packetHash, publicKey){
+		packet := getPacketFromListByHashAndPublicKey(blockPackets(lastBlock), packetHash, publicKey)
 		return packet
 	} else {
 		// packet was not found in the block's packet list
@@ -5,9 +5,9 @@ import(
 	"testing"
 )
 
-func generateMockChain() Blockchain {
+func generateMockChain(t *testing.T) Blockchain {
 	// create mock blockchain for use
-	difficulty = 4294967295 // all hashses pass
+	useDifficulty(t, 4294967295) // all hashes pass
 
 	// create 4 different valid packets
 	keys01 := GenerateNewKeypair()
@@ -61,7 +61,7 @@ func generateMockChain() Blockchain {
 
 
 func TestFindPacketByHash(t *testing.T){
-	chain    := generateMockChain()
+	chain    := generateMockChain(t)
 	packet02 := chain.Blocks[2].Data[0]
 	// fmt.Printf("searching for packet hash: %v \n", packet02)
 
@@ -88,7 +88,7 @@ func TestFindPacketByHash(t *testing.T){
 }
 
 func TestGetLastBlock(t *testing.T){
-	chain := generateMockChain()
+	chain := generateMockChain(t)
 	lastBlock := chain.Blocks[4]
 
 	if string(chain.getLastBlock().Hash) != string(lastBlock.Hash){
@@ -97,7 +97,7 @@ func TestGetLastBlock(t *testing.T){
 }
 
 func TestAddBlock(t *testing.T){
-	chain := generateMockChain()
+	chain := generateMockChain(t)
 	lastBlock := chain.getLastBlock()
 
 	b5 := &Block{Index: lastBlock.Index + 1,
@@ -117,7 +117,7 @@ func TestAddBlock(t *testing.T){
 }
 
 func TestIsValidChain(t *testing.T){
-	chain := generateMockChain()
+	chain := generateMockChain(t)
 
 	// test valid chain
 	if !chain.isValidChain(){
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+)
+
+/*
+chaincheck.go checks a whole blockchain, for checkchain, and rebuilds the
+indexes a node keeps beside its blockchain, for reindex.  A node checks
+each block as it arrives, but a blockchain saved in the data directory
+can be damaged on disk, and isValidChain only says whether a chain is
+valid, not where it goes wrong.  checkChain makes the checks of
+validateNextBlock, which isValidNextBlock makes too, and hasValidAuthority
+one block at a time, from the genesis block up, so it can name the first
+bad block and why it is bad.
+*/
+
+// the first bad block of a blockchain; Height is its position in the chain, as its index may be the fault
+type chainFault struct {
+	Height uint32
+	Reason string
+}
+
+func (fault *chainFault) Error() string {
+	return fmt.Sprintf("block #%v %v", fault.Height, fault.Reason)
+}
+
+// reports what changed when the node's indexes were rebuilt
+type reindexStats struct {
+	Blocks         int // blocks indexed
+	Missing        int // blocks of the blockchain that were not in the seen blocks
+	Stale          int // seen blocks that are not on the blockchain
+	DroppedPackets int // mempool packets already on the blockchain
+	DroppedRecords int
+}
+
+// checks every block of the blockchain in order, returning a *chainFault for the first bad one
+func (blockchain Blockchain) checkChain() error {
+	if len(blockchain.Blocks) == 0 {
+		return &chainFault{Height: 0, Reason: "is missing, the blockchain is empty"}
+	}
+	if string(blockchain.Blocks[0].Hash) != string(genesisBlock.Hash) {
+		return &chainFault{Height: 0, Reason: "is not this network's genesis block"}
+	}
+
+	_, poa      := consensus.(poaEngine)
+	authorities := genesisAuthorities
+	epoch       := uint32(0)
+	for i, block := range blockchain.Blocks {
+		height := uint32(i)
+		if i > 0 {
+			if err := validateNextBlock(&blockchain.Blocks[i-1], &blockchain.Blocks[i]); err != nil {
+				return &chainFault{Height: height, Reason: err.Error()}
+			}
+		}
+		if i > 0 && poa {
+			if authorityPosition(authorities, block.Signer) < 0 {
+				return &chainFault{Height: height, Reason: fmt.Sprintf("was sealed by %v, which is not an authority", formatKey(block.Signer))}
+			}
+			if !mayAuthoritySeal(blockchain.Blocks[:i], authorities, block.Signer) {
+				return &chainFault{Height: height, Reason: fmt.Sprintf("was sealed by %v, which sealed one of the %v blocks before it", formatKey(block.Signer), len(authorities)/2)}
+			}
+			var approved bool
+			authorities, epoch, approved = applyBlockAuthorityChanges(authorities, epoch, block)
+			if !approved {
+				return &chainFault{Height: height, Reason: "holds an authority change that was not approved by most authorities"}
+			}
+		}
+	}
+	return nil
+}
+
+// rebuilds the seen blocks from the blockchain alone, and drops packets and
+// records from the mempool that are already on the blockchain
+func (n *Node) reindex() reindexStats {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	seenBlocks := map[string]bool{}
+	stats      := reindexStats{Blocks: len(n.blockchain.Blocks)}
+	for _, block := range n.blockchain.Blocks {
+		seenBlocks[string(block.Hash)] = true
+		if !n.seenBlocks[string(block.Hash)] {
+			stats.Missing = stats.Missing + 1
+		}
+	}
+	for blockHash := range n.seenBlocks {
+		if !seenBlocks[blockHash] {
+			stats.Stale = stats.Stale + 1
+		}
+	}
+	n.seenBlocks = seenBlocks
+
+	packets := []Packet{}
+	for _, packet := range n.curPacketList {
+		if n.blockchain.hasPacket(packet) {
+			stats.DroppedPackets = stats.DroppedPackets + 1
+		} else {
+			packets = append(packets, packet)
+		}
+	}
+	records := []Record{}
+	for _, record := range n.curRecordList {
+		if n.blockchain.hasRecord(record) {
+			stats.DroppedRecords = stats.DroppedRecords + 1
+		} else {
+			records = append(records, record)
+		}
+	}
+	n.curPacketList = packets
+	n.curRecordList = records
+	return stats
+}
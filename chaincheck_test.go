@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// relinks and rehashes the blocks of blockchain from index from on, after a test changes them
+func rehashFrom(blockchain Blockchain, from int) {
+	for i := from; i < len(blockchain.Blocks); i++ {
+		blockchain.Blocks[i].PrevHash = blockchain.Blocks[i-1].Hash
+		blockchain.Blocks[i].Hash     = blockchain.Blocks[i].calcHashForBlock(blockchain.Blocks[i].Nonce)
+	}
+}
+
+// checks that blockchain fails at height for a reason mentioning want
+func assertChainFault(t *testing.T, blockchain Blockchain, height uint32, want string) {
+	t.Helper()
+	var fault *chainFault
+	if err := blockchain.checkChain(); !errors.As(err, &fault) {
+		t.Error("Damaged blockchain passed the check, expected it to fail because it " + want)
+	} else if fault.Height != height || !strings.Contains(fault.Reason, want) {
+		t.Errorf("Expected block #%v to fail because it %v, got: %v", height, want, err)
+	}
+}
+
+func TestCheckChain(t *testing.T) {
+	useEngine(t, devEngine{})
+	keys   := GenerateNewKeypair()
+	hash   := hashDocument([]byte("checkchain"))
+	packet := Packet{Hash: hash, Owner: keys.Public, Signature: signHash(hash, *keys)}
+
+	intact := testBlockchain(3)
+	intact.Blocks[1].Data = []Packet{packet}
+	rehashFrom(intact, 1)
+	if err := intact.checkChain(); err != nil {
+		t.Error("Intact blockchain failed the check:", err)
+	}
+
+	unlinked := testBlockchain(3)
+	unlinked.Blocks[2].PrevHash = []byte("elsewhere")
+	unlinked.Blocks[2].Hash     = unlinked.Blocks[2].calcHashForBlock(0)
+	assertChainFault(t, unlinked, 2, "does not link to the block before it")
+
+	tampered := testBlockchain(3)
+	tampered.Blocks[3].Nonce = 1
+	assertChainFault(t, tampered, 3, "contents hash to")
+
+	renumbered := testBlockchain(2)
+	renumbered.Blocks[1].Index = 5
+	rehashFrom(renumbered, 1)
+	assertChainFault(t, renumbered, 1, "has index 5")
+
+	forged := testBlockchain(2)
+	forged.Blocks[2].Data = []Packet{{Hash: hash, Owner: keys.Public, Signature: []byte("forged")}}
+	rehashFrom(forged, 2)
+	assertChainFault(t, forged, 2, "signature or metadata is invalid")
+
+	otherGenesis := testBlockchain(1)
+	otherGenesis.Blocks[0].Hash = []byte("another network")
+	assertChainFault(t, otherGenesis, 0, "genesis block")
+}
+
+func TestCheckChainProofOfWork(t *testing.T) {
+	useEngine(t, powEngine{})
+	useDifficulty(t, 0) // no hash passes
+
+	assertChainFault(t, testBlockchain(2), 1, "is not mined")
+}
+
+func TestReindex(t *testing.T) {
+	keys     := GenerateNewKeypair()
+	hash     := hashDocument([]byte("reindex"))
+	mined    := Packet{Hash: hash, Owner: keys.Public, Signature: signHash(hash, *keys)}
+	waiting  := Packet{Hash: hashDocument([]byte("waiting")), Owner: keys.Public}
+	n        := newNode()
+	n.blockchain = testBlockchain(2)
+	n.blockchain.Blocks[2].Data = []Packet{mined}
+	n.seenBlocks    = map[string]bool{string(n.blockchain.Blocks[1].Hash): true, "orphan": true}
+	n.curPacketList = []Packet{mined, waiting}
+
+	stats := n.reindex()
+	if stats.Blocks != 3 || stats.Missing != 2 || stats.Stale != 1 || stats.DroppedPackets != 1 {
+		t.Errorf("Reindex did not report what it changed: %+v", stats)
+	}
+	if len(n.seenBlocks) != 3 || !n.seenBlocks[string(n.blockchain.Blocks[2].Hash)] || n.seenBlocks["orphan"] {
+		t.Error("Seen blocks were not rebuilt from the blockchain")
+	}
+	if len(n.curPacketList) != 1 || !equalPackets(n.curPacketList[0], waiting) {
+		t.Error("Mempool still holds a packet that is already mined")
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"time"
+)
+
+/*
+chainfile.go moves a blockchain between nodes through a file, for
+export-chain and import-chain.  The file starts with a header, the magic
+bytes GOBCHAIN and a version, followed by one frame per block in order
+from the genesis block:
+
+  length   uint32, little endian, of the payload
+  checksum uint32, CRC-32C of the payload
+  payload  the block, gob encoded on its own
+
+Each frame can be read without the ones before it, so a chain is written
+and read a block at a time however long it is.  Import checks every block
+with isValidNextBlock as if it had come from a peer, and skips blocks the
+node already has, so an interrupted import picks up where it stopped.
+*/
+
+const chainFileMagic   = "GOBCHAIN"
+const chainFileVersion uint16 = 1
+const maxChainFrame    = 64 << 20 // no block comes near this, a bigger length means a corrupt file
+
+const importSettleTimeout = 5 * time.Second
+
+var chainFileChecksum = crc32.MakeTable(crc32.Castagnoli)
+
+type chainWriter struct {
+	w *bufio.Writer
+}
+
+func newChainWriter(w io.Writer) (*chainWriter, error) {
+	writer := &chainWriter{w: bufio.NewWriter(w)}
+	header := make([]byte, len(chainFileMagic)+2)
+	copy(header, chainFileMagic)
+	binary.LittleEndian.PutUint16(header[len(chainFileMagic):], chainFileVersion)
+	_, err := writer.w.Write(header)
+	return writer, err
+}
+
+func (writer *chainWriter) writeBlock(block Block) error {
+	payload := &bytes.Buffer{}
+	if err := gob.NewEncoder(payload).Encode(block); err != nil {
+		return err
+	}
+	frame := make([]byte, 8)
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(payload.Len()))
+	binary.LittleEndian.PutUint32(frame[4:8], crc32.Checksum(payload.Bytes(), chainFileChecksum))
+	if _, err := writer.w.Write(frame); err != nil {
+		return err
+	}
+	_, err := writer.w.Write(payload.Bytes())
+	return err
+}
+
+func (writer *chainWriter) flush() error {
+	return writer.w.Flush()
+}
+
+type chainReader struct {
+	r      *bufio.Reader
+	frames int // frames read so far
+}
+
+// reads and checks the header
+func newChainReader(r io.Reader) (*chainReader, error) {
+	reader := &chainReader{r: bufio.NewReader(r)}
+	header := make([]byte, len(chainFileMagic)+2)
+	if _, err := io.ReadFull(reader.r, header); err != nil || string(header[:len(chainFileMagic)]) != chainFileMagic {
+		return nil, errors.New("not a chain file")
+	}
+	if version := binary.LittleEndian.Uint16(header[len(chainFileMagic):]); version != chainFileVersion {
+		return nil, fmt.Errorf("chain file version %v is not supported, only version %v", version, chainFileVersion)
+	}
+	return reader, nil
+}
+
+// the next block, or io.EOF after the last one
+func (reader *chainReader) readBlock() (Block, error) {
+	var block Block
+	frame := make([]byte, 8)
+	if _, err := io.ReadFull(reader.r, frame); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return block, fmt.Errorf("frame %v is cut short, the file was not fully written", reader.frames)
+		}
+		return block, err // io.EOF between frames is the end of the chain
+	}
+	length   := binary.LittleEndian.Uint32(frame[0:4])
+	checksum := binary.LittleEndian.Uint32(frame[4:8])
+	if length > maxChainFrame {
+		return block, fmt.Errorf("frame %v is corrupt, it claims to be %v bytes", reader.frames, length)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader.r, payload); err != nil {
+		return block, fmt.Errorf("frame %v is cut short, the file was not fully written", reader.frames)
+	}
+	if crc32.Checksum(payload, chainFileChecksum) != checksum {
+		return block, fmt.Errorf("frame %v is corrupt, its checksum does not match", reader.frames)
+	}
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&block); err != nil {
+		return block, fmt.Errorf("frame %v is corrupt: %v", reader.frames, err)
+	}
+	reader.frames = reader.frames + 1
+	return block, nil
+}
+
+// writes the whole blockchain to filePath, replacing it only once every block is written
+func exportChain(filePath string, blockchain Blockchain) error {
+	return writeFileAtomic(filePath, func(file *os.File) error {
+		writer, err := newChainWriter(file)
+		if err != nil {
+			return err
+		}
+		for _, block := range blockchain.Blocks {
+			if err := writer.writeBlock(block); err != nil {
+				return err
+			}
+		}
+		return writer.flush()
+	})
+}
+
+// adds the blocks of a chain file the node does not have yet, checking each
+// one follows the last; returns how many blocks were added, which are kept
+// when a later block fails so running the import again carries on from there
+func importChain(n *Node, filePath string) (int, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	reader, err := newChainReader(file)
+	if err != nil {
+		return 0, err
+	}
+
+	blockchain := n.getBlockchain()
+	imported   := 0
+	for err == nil {
+		var block Block
+		block, err = reader.readBlock()
+		if err == io.EOF {
+			err = nil
+			break
+		}
+		if err != nil {
+			break
+		}
+
+		// blocks the node already has are skipped, as long as they are the same blocks
+		lastBlock := blockchain.getLastBlock()
+		if block.Index <= lastBlock.Index {
+			if int(block.Index) >= len(blockchain.Blocks) || string(blockchain.Blocks[block.Index].Hash) != string(block.Hash) {
+				err = fmt.Errorf("block %v differs from the one on this node's blockchain", block.Index)
+			}
+			continue
+		}
+		if !lastBlock.isValidNextBlock(&block) || !blockchain.hasValidAuthority(&block) {
+			err = fmt.Errorf("block %v is invalid", block.Index)
+			break
+		}
+
+		// the node's loop handles blocks in the order they are sent, so this one is added before the next is sent
+		select {
+		case n.blockWrapperChannel <- &BlockWrapper{Block: block, Sender: n.getAddress()}:
+		case <-n.ctx.Done():
+			return imported, n.ctx.Err()
+		}
+		blockchain.Blocks = append(blockchain.Blocks[:len(blockchain.Blocks):len(blockchain.Blocks)], block)
+		imported = imported + 1
+	}
+
+	// the last block is added once the node's loop gets round to it
+	deadline := time.Now().Add(importSettleTimeout)
+	for imported > 0 && string(n.getBlockchain().getLastBlock().Hash) != string(blockchain.getLastBlock().Hash) {
+		if time.Now().After(deadline) {
+			return imported, errors.New("the node's blockchain changed during the import, run it again to check the rest")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return imported, err
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// a blockchain of count blocks after the genesis block; hashes only pass with the difficulty newSimNetwork sets
+func testBlockchain(count int) Blockchain {
+	blockchain := Blockchain{[]Block{genesisBlock}}
+	for i := 0; i < count; i++ {
+		lastBlock := blockchain.getLastBlock()
+		block := Block{Index: lastBlock.Index + 1, PrevHash: lastBlock.Hash, Data: []Packet{}, Records: []Record{}}
+		block.Hash = block.calcHashForBlock(0)
+		blockchain.Blocks = append(blockchain.Blocks, block)
+	}
+	return blockchain
+}
+
+func TestChainFileRoundTrip(t *testing.T) {
+	blockchain := testBlockchain(3)
+	buffer := &bytes.Buffer{}
+	writer, _ := newChainWriter(buffer)
+	for _, block := range blockchain.Blocks {
+		writer.writeBlock(block)
+	}
+	writer.flush()
+
+	reader, err := newChainReader(bytes.NewReader(buffer.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range blockchain.Blocks {
+		block, err := reader.readBlock()
+		if err != nil || string(block.Hash) != string(want.Hash) {
+			t.Error("Block was not read back:", err)
+		}
+	}
+	if _, err := reader.readBlock(); err == nil {
+		t.Error("Read a block past the end of the file")
+	}
+}
+
+func TestChainFileCatchesDamage(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	writer, _ := newChainWriter(buffer)
+	writer.writeBlock(genesisBlock)
+	writer.flush()
+	file := buffer.Bytes()
+
+	corrupt := append([]byte{}, file...)
+	corrupt[len(corrupt)-1] ^= 0xff
+	reader, _ := newChainReader(bytes.NewReader(corrupt))
+	if _, err := reader.readBlock(); err == nil {
+		t.Error("Read a block whose checksum does not match")
+	}
+
+	reader, _ = newChainReader(bytes.NewReader(file[:len(file)-3]))
+	if _, err := reader.readBlock(); err == nil {
+		t.Error("Read a block that was cut short")
+	}
+
+	newer := append([]byte{}, file...)
+	newer[len(chainFileMagic)] = byte(chainFileVersion + 1)
+	if _, err := newChainReader(bytes.NewReader(newer)); err == nil {
+		t.Error("Read a chain file of another version")
+	}
+	if _, err := newChainReader(bytes.NewReader([]byte("not a chain file"))); err == nil {
+		t.Error("Read a file that is not a chain file")
+	}
+}
+
+func TestImportChain(t *testing.T) {
+	sim  := newSimNetwork(t, 1)
+	n    := sim.nodes[0]
+	dir  := t.TempDir()
+	full := filepath.Join(dir, "chain.gbc")
+	if err := exportChain(full, testBlockchain(4)); err != nil {
+		t.Fatal(err)
+	}
+
+	// an import cut short keeps the blocks before the cut, and carries on from them next time
+	data, _ := os.ReadFile(full)
+	partial := filepath.Join(dir, "partial.gbc")
+	os.WriteFile(partial, data[:len(data)-10], 0644)
+	if imported, err := importChain(n, partial); err == nil || imported != 3 {
+		t.Error("Import of a cut short file did not stop at the cut:", imported, err)
+	}
+	if imported, err := importChain(n, full); err != nil || imported != 1 || n.getBlockchain().getLastBlock().Index != 4 {
+		t.Error("Import did not carry on where it stopped:", imported, err)
+	}
+	if imported, err := importChain(n, full); err != nil || imported != 0 {
+		t.Error("Import added blocks the node already had:", imported, err)
+	}
+
+	// every block is validated
+	invalid := testBlockchain(6)
+	invalid.Blocks[5].Data = []Packet{{Hash: []byte("forged")}}
+	invalidPath := filepath.Join(dir, "invalid.gbc")
+	exportChain(invalidPath, invalid)
+	if imported, err := importChain(n, invalidPath); err == nil || imported != 0 || n.getBlockchain().getLastBlock().Index != 4 {
+		t.Error("Imported an invalid block:", imported, err)
+	}
+
+	// a chain that forks from the node's is not mixed in
+	fork := testBlockchain(5)
+	fork.Blocks[2].Records = []Record{newRecord(annotationRecord, Annotation{})}
+	fork.Blocks[2].Hash    = fork.Blocks[2].calcHashForBlock(0)
+	forkPath := filepath.Join(dir, "fork.gbc")
+	exportChain(forkPath, fork)
+	if _, err := importChain(n, forkPath); err == nil {
+		t.Error("Imported a chain that forks from the node's")
+	}
+}
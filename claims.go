@@ -0,0 +1,144 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+)
+
+/*
+claims.go holds two small record types: identity claims, where a key
+states a human readable name for its owner, and annotations, short signed
+notes attached to a document hash.  Both are signed by the key making
+them and are limited in size so they can not be used to bloat the chain.
+*/
+
+const maxClaimNameLength  = 64
+const maxAnnotationLength = 256
+
+type IdentityClaim struct {
+	Key       []byte
+	Name      string
+	Signature []byte
+}
+
+type Annotation struct {
+	Author    []byte
+	Subject   []byte // hash of the annotated document, may be empty
+	Text      string
+	Signature []byte
+}
+
+func identityClaimDigest(claim IdentityClaim) []byte {
+	h := sha256.New()
+	h.Write([]byte{identityRecord})
+	h.Write(lengthPrefixed(claim.Key))
+	h.Write(lengthPrefixed([]byte(claim.Name)))
+	return h.Sum(nil)
+}
+
+func annotationDigest(annotation Annotation) []byte {
+	h := sha256.New()
+	h.Write([]byte{annotationRecord})
+	h.Write(lengthPrefixed(annotation.Author))
+	h.Write(lengthPrefixed(annotation.Subject))
+	h.Write(lengthPrefixed([]byte(annotation.Text)))
+	return h.Sum(nil)
+}
+
+func createIdentityClaim(keys Keypair, name string) IdentityClaim {
+	claim := IdentityClaim{Key: keys.Public, Name: name}
+	claim.Signature = signHash(identityClaimDigest(claim), keys)
+	return claim
+}
+
+func createAnnotation(keys Keypair, subject []byte, text string) Annotation {
+	annotation := Annotation{Author: keys.Public, Subject: subject, Text: text}
+	annotation.Signature = signHash(annotationDigest(annotation), keys)
+	return annotation
+}
+
+func verifyIdentityClaim(claim IdentityClaim) bool {
+	if len(claim.Name) == 0 || len(claim.Name) > maxClaimNameLength {
+		return false
+	}
+	return SignatureVerify(claim.Key, claim.Signature, identityClaimDigest(claim))
+}
+
+func verifyAnnotation(annotation Annotation) bool {
+	if len(annotation.Text) == 0 || len(annotation.Text) > maxAnnotationLength {
+		return false
+	}
+	return SignatureVerify(annotation.Author, annotation.Signature, annotationDigest(annotation))
+}
+
+func init() {
+	registerRecordType(identityRecord, recordType{
+		name: "identity claim",
+		validate: func(record Record, block *Block) bool {
+			var claim IdentityClaim
+			return decodeRecord(record, &claim) == nil && verifyIdentityClaim(claim)
+		},
+		hash: func(record Record) []byte {
+			var claim IdentityClaim
+			decodeRecord(record, &claim)
+			return append(identityClaimDigest(claim), lengthPrefixed(claim.Signature)...)
+		},
+		describe: func(record Record) string {
+			var claim IdentityClaim
+			decodeRecord(record, &claim)
+			return fmt.Sprintf("%v claims to be %q", string(claim.Key), claim.Name)
+		},
+	})
+
+	registerRecordType(annotationRecord, recordType{
+		name: "annotation",
+		validate: func(record Record, block *Block) bool {
+			var annotation Annotation
+			return decodeRecord(record, &annotation) == nil && verifyAnnotation(annotation)
+		},
+		hash: func(record Record) []byte {
+			var annotation Annotation
+			decodeRecord(record, &annotation)
+			return append(annotationDigest(annotation), lengthPrefixed(annotation.Signature)...)
+		},
+		describe: func(record Record) string {
+			var annotation Annotation
+			decodeRecord(record, &annotation)
+			return fmt.Sprintf("%v notes on %x: %q", string(annotation.Author), annotation.Subject, annotation.Text)
+		},
+	})
+}
+
+// returns the names the key has claimed, oldest first
+func (blockchain Blockchain) findIdentityClaims(publicKey []byte) []string {
+	names := []string{}
+	for _, block := range blockchain.Blocks {
+		for _, record := range block.Records {
+			var claim IdentityClaim
+			if record.Type != identityRecord || decodeRecord(record, &claim) != nil {
+				continue
+			}
+			if string(claim.Key) == string(publicKey) {
+				names = append(names, claim.Name)
+			}
+		}
+	}
+	return names
+}
+
+// returns the annotations made on a document hash, oldest first
+func (blockchain Blockchain) findAnnotations(subject []byte) []Annotation {
+	annotations := []Annotation{}
+	for _, block := range blockchain.Blocks {
+		for _, record := range block.Records {
+			var annotation Annotation
+			if record.Type != annotationRecord || decodeRecord(record, &annotation) != nil {
+				continue
+			}
+			if string(annotation.Subject) == string(subject) {
+				annotations = append(annotations, annotation)
+			}
+		}
+	}
+	return annotations
+}
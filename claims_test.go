@@ -0,0 +1,54 @@
+package main
+
+import(
+	"strings"
+	"testing"
+)
+
+func TestVerifyIdentityClaim(t *testing.T){
+	keys01 := GenerateNewKeypair()
+	keys02 := GenerateNewKeypair()
+
+	if !verifyIdentityClaim(createIdentityClaim(*keys01, "Nick von Pentz")) {
+		t.Error("fails to approve valid identity claim")
+	}
+	if verifyIdentityClaim(createIdentityClaim(*keys01, "")) {
+		t.Error("approves empty identity claim")
+	}
+	if verifyIdentityClaim(createIdentityClaim(*keys01, strings.Repeat("n", maxClaimNameLength+1))) {
+		t.Error("approves identity claim over the size limit")
+	}
+
+	claim := createIdentityClaim(*keys01, "Nick")
+	claim.Key = keys02.Public
+	if verifyIdentityClaim(claim) {
+		t.Error("approves identity claim for someone else's key")
+	}
+}
+
+func TestVerifyAnnotation(t *testing.T){
+	keys01 := GenerateNewKeypair()
+	subject := hashDocument([]byte("document"))
+
+	if !verifyAnnotation(createAnnotation(*keys01, subject, "first draft")) {
+		t.Error("fails to approve valid annotation")
+	}
+	if verifyAnnotation(createAnnotation(*keys01, subject, strings.Repeat("n", maxAnnotationLength+1))) {
+		t.Error("approves annotation over the size limit")
+	}
+
+	annotation := createAnnotation(*keys01, subject, "first draft")
+	annotation.Text = "final draft"
+	if verifyAnnotation(annotation) {
+		t.Error("approves annotation changed after signing")
+	}
+
+	chain := Blockchain{Blocks: []Block{genesisBlock, {Records: []Record{newRecord(annotationRecord, createAnnotation(*keys01, subject, "first draft")),
+		newRecord(identityRecord, createIdentityClaim(*keys01, "Nick"))}}}}
+	if len(chain.findAnnotations(subject)) != 1 {
+		t.Error("fails to find annotation by subject")
+	}
+	if names := chain.findIdentityClaims(keys01.Public); len(names) != 1 || names[0] != "Nick" {
+		t.Error("fails to find identity claim by key")
+	}
+}
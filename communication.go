@@ -9,6 +9,10 @@ through sending this communication object.  The ID represents the type:
 2 - means we were requested to send conections
 3 - means we will be receiving a blockchain
 4 - means we were requested to send your blockchain 
+5 - means we will be receiving a packet
+6 - means we will be receiving a typed record
+7 - means the peer is shutting down and will close the connection
+8 - means we will be receiving the peer's version handshake
 */
 
 type Communication struct {
@@ -17,4 +21,6 @@ type Communication struct {
     SentAddresses []string
     Blockchain    Blockchain
     Packet 		  Packet
+    Record        Record
+    Version       Version
 }
\ No newline at end of file
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+/*
+config.go holds the settings of a node.  They are resolved in order, each
+step overriding the one before:
+
+  defaults -> config file (--config) -> environment -> command line flags
+
+The config file is TOML or YAML, picked by its extension, with one
+section per part of the node.  Every setting can be overridden by an
+environment variable named GOBLOCKCHAIN_<SECTION>_<KEY>, eg.
+GOBLOCKCHAIN_MINING_DIFFICULTY=100.
+*/
+
+const envPrefix = "GOBLOCKCHAIN"
+
+type Config struct {
+	Network   NetworkConfig   `toml:"network"   yaml:"network"`
+	Mining    MiningConfig    `toml:"mining"    yaml:"mining"`
+	Consensus ConsensusConfig `toml:"consensus" yaml:"consensus"`
+	Storage   StorageConfig   `toml:"storage"   yaml:"storage"`
+	API       APIConfig       `toml:"api"       yaml:"api"`
+	Logging   LoggingConfig   `toml:"logging"   yaml:"logging"`
+	Output    OutputConfig    `toml:"output"    yaml:"output"`
+}
+
+type NetworkConfig struct {
+	ListenPort   string   `toml:"listen_port"   yaml:"listen_port"`
+	Seeds        []string `toml:"seeds"         yaml:"seeds"`         // ports, IPs when public, or host:port
+	SeedsFile    string   `toml:"seeds_file"    yaml:"seeds_file"`    // more seeds, one or more per line
+	DNSSeed      string   `toml:"dns_seed"      yaml:"dns_seed"`      // a name whose TXT records list seeds
+	Public       bool     `toml:"public"        yaml:"public"`
+	DefaultPort  string   `toml:"default_port"  yaml:"default_port"`  // the port public seeds listen on
+	ExternalAddr string   `toml:"external_addr" yaml:"external_addr"` // host:port to advertise, instead of the address peers see
+	Transport    string   `toml:"transport"     yaml:"transport"`     // tcp, tls or memory, see transport.go
+	TLSCert      string   `toml:"tls_cert"      yaml:"tls_cert"`      // PEM certificate the tls transport presents to peers
+	TLSKey       string   `toml:"tls_key"       yaml:"tls_key"`
+	TLSCA        string   `toml:"tls_ca"        yaml:"tls_ca"`        // PEM CA peers' certificates must be signed by, empty to not check them
+	IdentityKey  string   `toml:"identity_key"  yaml:"identity_key"`  // the node's ed25519 key, empty for identity.key in the data directory
+	AllowedPeers []string `toml:"allowed_peers" yaml:"allowed_peers"` // IDs of the only peers the tls transport accepts, empty for any
+}
+
+type MiningConfig struct {
+	Difficulty uint32 `toml:"difficulty" yaml:"difficulty"` // every node on a network must use the same difficulty
+}
+
+type ConsensusConfig struct {
+	Engine       string   `toml:"engine"        yaml:"engine"`        // pow, poa or dev, see consensus.go
+	Authorities  []string `toml:"authorities"   yaml:"authorities"`   // public keys of the first authorities, the same on every node
+	AuthorityKey string   `toml:"authority_key" yaml:"authority_key"` // JSON keypair this node seals blocks with, empty if it is not an authority
+	Period       uint32   `toml:"period"        yaml:"period"`        // seconds an authority waits after a block before sealing the next
+}
+
+type StorageConfig struct {
+	DataDir string `toml:"data_dir" yaml:"data_dir"` // empty to keep nothing between runs
+}
+
+type APIConfig struct {
+	Address string `toml:"address" yaml:"address"` // empty to disable the HTTP API
+}
+
+type LoggingConfig struct {
+	Level  string `toml:"level"  yaml:"level"`
+	Format string `toml:"format" yaml:"format"`
+	File   string `toml:"file"   yaml:"file"` // empty to log to stderr
+}
+
+type OutputConfig struct {
+	Format string `toml:"format" yaml:"format"` // text or json, how commands print blocks and packets (see format.go)
+}
+
+func defaultConfig() Config {
+	return Config{Network:   NetworkConfig{ListenPort:   "1999",
+	                                       Seeds:        []string{},
+	                                       SeedsFile:    "",
+	                                       DNSSeed:      "",
+	                                       Public:       false,
+	                                       DefaultPort:  "1999",
+	                                       ExternalAddr: "",
+	                                       Transport:    "tcp",
+	                                       TLSCert:      "",
+	                                       TLSKey:       "",
+	                                       TLSCA:        "",
+	                                       IdentityKey:  "",
+	                                       AllowedPeers: []string{}},
+	              Mining:    MiningConfig{Difficulty: 200},
+	              Consensus: ConsensusConfig{Engine: "pow", Authorities: []string{}, AuthorityKey: "", Period: 5},
+	              Storage:   StorageConfig{DataDir: ""},
+	              API:       APIConfig{Address: ""},
+	              Logging:   LoggingConfig{Level: "info", Format: "logfmt", File: ""},
+	              Output:    OutputConfig{Format: "text"}}
+}
+
+// reads a TOML or YAML config file over the given config; unknown keys are
+// an error so a misspelt setting is not silently ignored
+func loadConfigFile(config *Config, filePath string) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".toml":
+		metadata, err := toml.Decode(string(data), config)
+		if err != nil {
+			return err
+		}
+		if undecoded := metadata.Undecoded(); len(undecoded) != 0 {
+			return fmt.Errorf("unknown setting %v in %v", undecoded[0], filePath)
+		}
+		return nil
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err := decoder.Decode(config)
+		if errors.Is(err, io.EOF) { // an empty file changes nothing
+			return nil
+		}
+		return err
+	default:
+		return fmt.Errorf("config file %v must end in .toml, .yaml or .yml", filePath)
+	}
+}
+
+// the environment variable overriding a setting, eg. GOBLOCKCHAIN_NETWORK_LISTEN_PORT
+func configEnvName(section, key string) string {
+	return strings.ToUpper(envPrefix + "_" + section + "_" + key)
+}
+
+// overrides settings with the environment variables that are set
+func applyConfigEnv(config *Config, lookupEnv func(string) (string, bool)) error {
+	sections := reflect.ValueOf(config).Elem()
+	for i := 0; i < sections.NumField(); i++ {
+		section     := sections.Field(i)
+		sectionName := sections.Type().Field(i).Tag.Get("toml")
+		for j := 0; j < section.NumField(); j++ {
+			name := configEnvName(sectionName, section.Type().Field(j).Tag.Get("toml"))
+			value, set := lookupEnv(name)
+			if !set {
+				continue
+			}
+			if err := setConfigField(section.Field(j), value); err != nil {
+				return fmt.Errorf("%v: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func setConfigField(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(parsed)
+	case reflect.Slice: // a comma separated list
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("settings of kind %v can not be set from the environment", field.Kind())
+		}
+		field.Set(reflect.ValueOf(parseSeedList([]string{value})))
+	case reflect.Uint32:
+		parsed, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return err
+		}
+		field.SetUint(parsed)
+	default:
+		return fmt.Errorf("settings of kind %v can not be set from the environment", field.Kind())
+	}
+	return nil
+}
+
+// checks the settings a node can not run without
+func validateConfig(config Config) error {
+	if _, err := strconv.ParseUint(config.Network.ListenPort, 10, 16); err != nil {
+		return fmt.Errorf("network.listen_port %q is not a port", config.Network.ListenPort)
+	}
+	if _, err := strconv.ParseUint(config.Network.DefaultPort, 10, 16); err != nil {
+		return fmt.Errorf("network.default_port %q is not a port", config.Network.DefaultPort)
+	}
+	if config.Network.ExternalAddr != "" {
+		if _, _, err := net.SplitHostPort(config.Network.ExternalAddr); err != nil {
+			return fmt.Errorf("network.external_addr %q is not a host:port", config.Network.ExternalAddr)
+		}
+	}
+	switch config.Network.Transport {
+	case "tcp", "memory":
+	case "tls":
+		if (config.Network.TLSCert == "") != (config.Network.TLSKey == "") {
+			return errors.New("network.tls_cert and network.tls_key must be set together")
+		}
+	default:
+		return fmt.Errorf("network.transport %q must be tcp, tls or memory", config.Network.Transport)
+	}
+	if len(config.Network.AllowedPeers) > 0 && config.Network.Transport != "tls" {
+		return errors.New("network.allowed_peers needs network.transport tls, other transports do not authenticate peers")
+	}
+	for _, id := range config.Network.AllowedPeers {
+		if decoded, err := hex.DecodeString(id); err != nil || len(decoded) != sha256.Size {
+			return fmt.Errorf("network.allowed_peers %q is not a peer ID", id)
+		}
+	}
+	switch config.Consensus.Engine {
+	case "pow", "dev":
+	case "poa":
+		if len(config.Consensus.Authorities) == 0 {
+			return errors.New("consensus.authorities must list at least one key for consensus.engine poa")
+		}
+		if config.Consensus.Period == 0 {
+			return errors.New("consensus.period must be at least one second")
+		}
+	default:
+		return fmt.Errorf("consensus.engine %q must be pow, poa or dev", config.Consensus.Engine)
+	}
+	switch config.Logging.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("logging.level %q must be debug, info, warn or error", config.Logging.Level)
+	}
+	switch config.Logging.Format {
+	case "json", "logfmt":
+	default:
+		return fmt.Errorf("logging.format %q must be json or logfmt", config.Logging.Format)
+	}
+	switch config.Output.Format {
+	case "text", "json":
+	default:
+		return fmt.Errorf("output.format %q must be text or json", config.Output.Format)
+	}
+	return nil
+}
+
+// the effective configuration as TOML, ready to be saved as a config file
+func dumpConfig(config Config) string {
+	buffer := &bytes.Buffer{}
+	toml.NewEncoder(buffer).Encode(config)
+	return buffer.String()
+}
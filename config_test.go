@@ -0,0 +1,172 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeTestConfig(t *testing.T, name string, contents string) string {
+	filePath := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(filePath, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filePath
+}
+
+func TestDefaultConfigIsValid(t *testing.T) {
+	config := defaultConfig()
+	if err := validateConfig(config); err != nil {
+		t.Error("Default config does not validate:", err)
+	}
+	if config.Network.ListenPort != "1999" || config.Mining.Difficulty != 200 {
+		t.Error("Default config does not match the documented defaults")
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	tomlPath := writeTestConfig(t, "node.toml", `
+[network]
+listen_port = "2000"
+seeds = ["1999", "10.0.0.2:1999"]
+
+[mining]
+difficulty = 100
+`)
+	config := defaultConfig()
+	if err := loadConfigFile(&config, tomlPath); err != nil {
+		t.Fatal(err)
+	}
+	if config.Network.ListenPort != "2000" || len(config.Network.Seeds) != 2 || config.Mining.Difficulty != 100 {
+		t.Error("TOML config file was not applied")
+	}
+	if config.Network.DefaultPort != "1999" {
+		t.Error("Settings missing from the config file should keep their defaults")
+	}
+
+	yamlPath := writeTestConfig(t, "node.yaml", `
+storage:
+  data_dir: /var/lib/go-blockchain
+logging:
+  level: debug
+`)
+	config = defaultConfig()
+	if err := loadConfigFile(&config, yamlPath); err != nil {
+		t.Fatal(err)
+	}
+	if config.Storage.DataDir != "/var/lib/go-blockchain" || config.Logging.Level != "debug" {
+		t.Error("YAML config file was not applied")
+	}
+
+	// misspelt settings and unknown formats are rejected
+	if loadConfigFile(&config, writeTestConfig(t, "typo.toml", "[mining]\ndificulty = 1\n")) == nil {
+		t.Error("Unknown TOML setting should be rejected")
+	}
+	if loadConfigFile(&config, writeTestConfig(t, "typo.yml", "mining:\n  dificulty: 1\n")) == nil {
+		t.Error("Unknown YAML setting should be rejected")
+	}
+	if loadConfigFile(&config, writeTestConfig(t, "node.ini", "")) == nil {
+		t.Error("Config file of an unknown format should be rejected")
+	}
+}
+
+func TestApplyConfigEnv(t *testing.T) {
+	env := map[string]string{"GOBLOCKCHAIN_NETWORK_LISTEN_PORT": "2001",
+	                         "GOBLOCKCHAIN_NETWORK_PUBLIC":      "true",
+	                         "GOBLOCKCHAIN_NETWORK_SEEDS":       "1999, 10.0.0.2:1999",
+	                         "GOBLOCKCHAIN_MINING_DIFFICULTY":   "50"}
+	lookupEnv := func(name string) (string, bool) { value, set := env[name]; return value, set }
+
+	config := defaultConfig()
+	if err := applyConfigEnv(&config, lookupEnv); err != nil {
+		t.Fatal(err)
+	}
+	if config.Network.ListenPort != "2001" || !config.Network.Public || config.Mining.Difficulty != 50 || len(config.Network.Seeds) != 2 {
+		t.Error("Environment overrides were not applied")
+	}
+
+	env["GOBLOCKCHAIN_MINING_DIFFICULTY"] = "hard"
+	err := applyConfigEnv(&config, lookupEnv)
+	if err == nil || !strings.Contains(err.Error(), "GOBLOCKCHAIN_MINING_DIFFICULTY") {
+		t.Error("Invalid environment override should be rejected, naming the variable")
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	config := defaultConfig()
+	config.Network.ListenPort = "port"
+	if validateConfig(config) == nil {
+		t.Error("Validates a listen port that is not a number")
+	}
+
+	config = defaultConfig()
+	config.Logging.Format = "xml"
+	if validateConfig(config) == nil {
+		t.Error("Validates an unknown log format")
+	}
+
+	config = defaultConfig()
+	config.Output.Format = "yaml"
+	if validateConfig(config) == nil {
+		t.Error("Validates an unknown output format")
+	}
+
+	config = defaultConfig()
+	config.Network.Transport = "udp"
+	if validateConfig(config) == nil {
+		t.Error("Validates an unknown transport")
+	}
+	config.Network.Transport = "tls"
+	config.Network.TLSCert   = "node.crt"
+	if validateConfig(config) == nil {
+		t.Error("Validates a tls certificate without its key")
+	}
+
+	config = defaultConfig()
+	config.Network.AllowedPeers = []string{strings.Repeat("ab", 32)}
+	if validateConfig(config) == nil {
+		t.Error("Validates allowed peers without the tls transport")
+	}
+	config.Network.Transport = "tls"
+	if err := validateConfig(config); err != nil {
+		t.Error("Allowed peers over tls are not valid:", err)
+	}
+	config.Network.AllowedPeers = []string{"10.0.0.1:1999"}
+	if validateConfig(config) == nil {
+		t.Error("Validates an allowed peer that is not an ID")
+	}
+
+	config = defaultConfig()
+	config.Consensus.Engine = "pos"
+	if validateConfig(config) == nil {
+		t.Error("Validates an unknown consensus engine")
+	}
+	config.Consensus.Engine = "poa"
+	if validateConfig(config) == nil {
+		t.Error("Validates proof of authority without authorities")
+	}
+	config.Consensus.Authorities = []string{string(GenerateNewKeypair().Public)}
+	if err := validateConfig(config); err != nil {
+		t.Error("Proof of authority with an authority is not valid:", err)
+	}
+	config.Consensus.Period = 0
+	if validateConfig(config) == nil {
+		t.Error("Validates a sealing period of zero")
+	}
+}
+
+func TestDumpConfig(t *testing.T) {
+	config := defaultConfig()
+	config.Storage.DataDir = "/tmp/node"
+
+	dumpPath := writeTestConfig(t, "dump.toml", dumpConfig(config))
+	loaded   := defaultConfig()
+	if err := loadConfigFile(&loaded, dumpPath); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, config) {
+		t.Error("Dumped config does not load back to the same settings")
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+)
+
+/*
+consensus.go decides how blocks are sealed and which seals are valid.  The
+miner and the chain call through the ConsensusEngine in consensus, picked
+by consensus.engine in the config:
+
+  pow  proof of work, a nonce giving a hash below the difficulty (mine.go)
+  poa  proof of authority, authorities sign blocks, mostly in turn (poa.go)
+  dev  seals a block as soon as a packet or record arrives, or the user
+       enters seal, for trying the node out and for tests (--dev)
+
+isValidNextBlock checks a block's index, links, packets, records and hash
+itself, and asks the engine only whether the block is sealed.
+*/
+
+type ConsensusEngine interface {
+	// fills in the engine's part of a block n is about to seal on top of blockchain
+	prepare(n *Node, blockchain Blockchain, block *Block)
+	// seals the next block on n's blockchain, false once the node shuts down
+	seal(n *Node) (Block, bool)
+	// whether the block is sealed, its hash is checked by isValidNextBlock
+	verifyHeader(block *Block) bool
+	// how much the block adds to the weight of its chain, blockchain being the blocks before it
+	work(blockchain Blockchain, block *Block) uint64
+}
+
+// set from the consensus section of the config, like difficulty
+var consensus ConsensusEngine = powEngine{}
+
+func newConsensusEngine(engine string) (ConsensusEngine, error) {
+	switch engine {
+	case "", "pow":
+		return powEngine{}, nil
+	case "poa":
+		return poaEngine{}, nil
+	case "dev":
+		return devEngine{}, nil
+	default:
+		return nil, fmt.Errorf("unknown consensus engine %q", engine)
+	}
+}
+
+// the block n would add to blockchain next, holding n's mempool, with the engine's part filled in
+func nextBlock(n *Node, blockchain Blockchain) Block {
+	lastBlock := blockchain.getLastBlock()
+	block     := Block{Index:    lastBlock.Index + 1,
+	                   Nonce:    0,
+	                   PrevHash: lastBlock.Hash,
+	                   Data:     n.getCurPacketList(),
+	                   Hash:     []byte{},
+	                   Records:  []Record{}}
+	for _, record := range n.getCurRecordList() {
+		if verifyRecord(record, &block) { // eg. a key record retiring a key after a height not reached yet waits
+			block.Records = append(block.Records, record)
+		}
+	}
+	consensus.prepare(n, blockchain, &block)
+	return block
+}
+
+// the weight of the blockchain; a node only swaps its blockchain for a heavier one
+func (blockchain Blockchain) totalWork() uint64 {
+	var work uint64
+	for i := range blockchain.Blocks {
+		work = work + consensus.work(Blockchain{blockchain.Blocks[:i]}, &blockchain.Blocks[i])
+	}
+	return work
+}
+
+// seals every block as soon as it is asked to.  The node's loop asks it
+// itself, see sealDevBlock, so the miner is not needed
+type devEngine struct{}
+
+func (devEngine) prepare(n *Node, blockchain Blockchain, block *Block) {}
+
+func (devEngine) seal(n *Node) (Block, bool) {
+	if n.ctx.Err() != nil {
+		return Block{}, false
+	}
+	block     := nextBlock(n, n.getBlockchain())
+	block.Hash = block.calcHashForBlock(block.Nonce)
+	return block, true
+}
+
+func (devEngine) verifyHeader(block *Block) bool {
+	return true
+}
+
+func (devEngine) work(blockchain Blockchain, block *Block) uint64 {
+	return 1
+}
+
+// under the dev engine, seals a block holding the mempool and adds it straight
+// away, when the mempool holds anything or force is set; called from the node's loop
+func (n *Node) sealDevBlock(force bool) {
+	if _, dev := consensus.(devEngine); !dev {
+		return
+	}
+	if !force && len(n.getCurPacketList()) == 0 && len(n.getCurRecordList()) == 0 {
+		return
+	}
+	block, sealed := consensus.seal(n)
+	if !sealed {
+		return
+	}
+	minerLog.Info("sealed block", "index", block.Index, "packets", len(block.Data), "records", len(block.Records))
+	n.handleBlockWrapper(&BlockWrapper{Block: block, Sender: n.getAddress()})
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// switches the package to another consensus engine for one test
+func useEngine(t *testing.T, engine ConsensusEngine) {
+	previous := consensus
+	consensus = engine
+	t.Cleanup(func() { consensus = previous })
+}
+
+// sets the difficulty for one test
+func useDifficulty(t *testing.T, target uint32) {
+	previous := difficulty
+	difficulty = target
+	t.Cleanup(func() { difficulty = previous })
+}
+
+func TestNewConsensusEngine(t *testing.T) {
+	for name, want := range map[string]ConsensusEngine{"pow": powEngine{}, "poa": poaEngine{}, "dev": devEngine{}} {
+		if engine, err := newConsensusEngine(name); err != nil || engine != want {
+			t.Error("Consensus engine was not picked: " + name)
+		}
+	}
+	if _, err := newConsensusEngine("pos"); err == nil {
+		t.Error("Picked an unknown consensus engine")
+	}
+}
+
+func TestProofOfWorkEngine(t *testing.T) {
+	useDifficulty(t, 4294967295) // all hashes pass
+
+	block := Block{Index: 1, PrevHash: genesisBlock.Hash, Data: []Packet{}, Records: []Record{}}
+	block.Hash = block.calcHashForBlock(0)
+	if !(powEngine{}).verifyHeader(&block) {
+		t.Error("Hash below the difficulty is not sealed")
+	}
+	easy := (powEngine{}).work(Blockchain{}, &block)
+	difficulty = 0
+	if (powEngine{}).verifyHeader(&block) {
+		t.Error("Hash above the difficulty is sealed")
+	}
+	if (powEngine{}).work(Blockchain{}, &block) <= easy {
+		t.Error("Block at a higher difficulty does not weigh more")
+	}
+	if (powEngine{}).verifyHeader(&Block{Hash: []byte{1}}) {
+		t.Error("Short hash is sealed")
+	}
+}
+
+func TestDevEngineSealsAtOnce(t *testing.T) {
+	useEngine(t, devEngine{})
+	useDifficulty(t, 0) // no hash passes, which the dev engine does not care about
+
+	n := newNode()
+	ctx, cancel := context.WithCancel(context.Background())
+	n.ctx = ctx
+	block, sealed := consensus.seal(n)
+	if !sealed || !genesisBlock.isValidNextBlock(&block) {
+		t.Error("Dev engine did not seal a valid block")
+	}
+	cancel()
+	if _, sealed := consensus.seal(n); sealed {
+		t.Error("Dev engine sealed after the node shut down")
+	}
+}
+
+func TestLighterBlockchainIsRejected(t *testing.T) {
+	useDifficulty(t, 4294967295) // all hashes pass
+
+	blocks := []Block{genesisBlock}
+	for i := 0; i < 2; i++ {
+		lastBlock := blocks[len(blocks)-1]
+		block := Block{Index: lastBlock.Index + 1, PrevHash: lastBlock.Hash, Data: []Packet{}, Records: []Record{}}
+		block.Hash = block.calcHashForBlock(0)
+		blocks = append(blocks, block)
+	}
+	heavy := Blockchain{blocks}
+	light := Blockchain{blocks[:2]}
+	if heavy.totalWork() <= light.totalWork() {
+		t.Error("Longer blockchain does not weigh more")
+	}
+
+	n := newNode()
+	n.blockchain = heavy
+	n.handleSentBlockchain(light, make(chan *BlockWrapper, 1))
+	if len(n.blockchain.Blocks) != 3 {
+		t.Error("Node swapped its blockchain for a lighter one")
+	}
+}
+
+func TestDevModeSealsOnArrival(t *testing.T) {
+	useEngine(t, devEngine{}) // before the nodes start, so it is switched back after they stop
+	sim := newSimNetwork(t, 2)
+	sim.connectAll()
+	sim.waitForPeers(1)
+
+	keys         := GenerateNewKeypair()
+	documentHash := hashDocument([]byte("dev"))
+	sim.nodes[0].packetChannel <- Packet{Hash: documentHash, Owner: keys.Public, Signature: signHash(documentHash, *keys)}
+	waitFor(5*time.Second, func() bool { return sim.nodes[1].getBlockchain().getLastBlock().Index == 1 })
+	blockchain := sim.nodes[1].getBlockchain()
+	if blockchain.getLastBlock().Index != 1 || !packetListHasPacketHashAndPublicKey(blockchain.getLastBlock().Data, documentHash, keys.Public) {
+		t.Fatal("packet was not sealed into a block as it arrived: " + sim.tips())
+	}
+	if len(sim.nodes[0].getCurPacketList()) != 0 || len(sim.nodes[1].getCurPacketList()) != 0 {
+		t.Error("sealed packet is still waiting to be mined")
+	}
+
+	sim.nodes[0].sealChannel <- true
+	if !waitFor(5*time.Second, func() bool { return sim.nodes[1].getBlockchain().getLastBlock().Index == 2 }) {
+		t.Error("seal did not seal an empty block: " + sim.tips())
+	}
+	time.Sleep(50 * time.Millisecond)
+	if sim.nodes[0].getBlockchain().getLastBlock().Index != 2 {
+		t.Error("more blocks were sealed than asked for: " + sim.tips())
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"fmt"
 	"strings"
+	"strconv"
     "encoding/hex"
 
     // "github.com/nvonpentz/go-hashable-keys"
@@ -15,52 +16,177 @@ control.go handles all user interaction with the node
 done by entering text via commandline
 */
 
-func listenForUserInput(blockWrapperChannel chan *BlockWrapper, packetChannel chan Packet, n *Node) {
+// every command handleUserInput knows, for tab completion in the TUI
+var nodeCommands = []string{"mine", "config", "quit", "getchain", "getconns", "node", "block", "genkeys", "newseed",
+                            "restore", "derive", "upload", "cosign", "multiupload", "rotate", "revoke",
+                            "claim", "annotate", "authority", "seal", "verifyfile", "batch", "verifyproof", "lookup", "export-chain",
+                            "import-chain", "checkchain", "reindex", "help"}
+
+func listenForUserInput(n *Node) {
     reader := bufio.NewReader(os.Stdin) //constantly be reading in from std in
     input, err := reader.ReadString('\n')
     if (err != nil || input == "\n") {
     } else {
         fmt.Println()
-        go handleUserInput(input, blockWrapperChannel, packetChannel, n)
+        go handleUserInput(input, n)
     }
 }
 
-func handleUserInput(input string, blockWrapperChannel chan *BlockWrapper, packetChannel chan Packet, n *Node) {
+func handleUserInput(input string, n *Node) {
     outgoingArgs := strings.Fields(strings.Split(input,"\n")[0]) // remove newline char and seperate into array by whitespace
     arg0 := strings.ToLower(outgoingArgs[0])
     switch arg0 {
     case "mine":
-        go mineBlock(blockWrapperChannel, n)
-        listenForUserInput(blockWrapperChannel, packetChannel, n)
+        if _, dev := consensus.(devEngine); dev {
+            fmt.Println("Under --dev blocks are sealed as soon as packets arrive, enter 'seal' to seal one now")
+        } else {
+            startMining(n)
+        }
+        listenForUserInput(n)
+    case "seal":
+        if _, dev := consensus.(devEngine); dev {
+            n.sealChannel <- true
+        } else {
+            fmt.Println("Only a node started with --dev seals blocks on demand, enter 'mine' to mine them")
+        }
+        listenForUserInput(n)
+    case "config":
+        if len(outgoingArgs) > 1 && strings.ToLower(outgoingArgs[1]) == "dump" {
+            fmt.Print(dumpConfig(n.config))
+        } else {
+            fmt.Println("Enter 'config dump' to print the configuration your node is running with")
+        }
+        fmt.Println()
+        listenForUserInput(n)
+    case "quit":
+        n.stop() // the node saves its state and closes its connections before the program exits
     case "getchain":
-        if n.seed == "" {
+        if n.getSeed() == "" {
             fmt.Println("You must have a seed node to request a blockchain")
         } else{
-            seedConn := n.getConnForAddress(n.seed)
+            seedConn := n.getConnForAddress(n.getSeed())
             requestBlockchain(seedConn)                        
         }
         fmt.Println()
-        listenForUserInput(blockWrapperChannel, packetChannel, n)
+        listenForUserInput(n)
 
     case "getconns":
-        if n.hasConnectionOfAddress(n.seed){
-            seedConn := n.getConnForAddress(n.seed)
-            fmt.Println("Requesting more connections from seed " + n.seed + " ...")
+        if n.hasConnectionOfAddress(n.getSeed()){
+            seedConn := n.getConnForAddress(n.getSeed())
+            fmt.Println("Requesting more connections from seed " + n.getSeed() + " ...")
             requestConnections(seedConn)
         } else {
             fmt.Println("You are not connected to your seed node to make a request..")
         }
         fmt.Println()
-        listenForUserInput(blockWrapperChannel, packetChannel, n)
+        listenForUserInput(n)
     case "node":
         n.printNode()
         fmt.Println()
-        listenForUserInput(blockWrapperChannel, packetChannel, n)
+        listenForUserInput(n)
+    case "block":
+        if len(outgoingArgs) < 2 {
+            fmt.Println("Enter 'block' followed by the index of a block or its hash in hex, eg. 'block 3'")
+            listenForUserInput(n)
+            break
+        }
+        block, found := n.getBlockchain().findBlock(outgoingArgs[1])
+        if found {
+            fmt.Print(formatBlock(block))
+        } else {
+            fmt.Printf("There is no block %v on your blockchain\n", outgoingArgs[1])
+        }
+        fmt.Println()
+        listenForUserInput(n)
     case "genkeys":
         keys := GenerateNewKeypair()
         fmt.Printf("Public: %v\nPrivate: %v\n", string(keys.Public), string(keys.Private))
         fmt.Println()
-        listenForUserInput(blockWrapperChannel, packetChannel, n)
+        listenForUserInput(n)
+    case "newseed":
+        mnemonic, err := newMnemonic(128)
+        if err != nil {
+            fmt.Println(err)
+            listenForUserInput(n)
+            break
+        }
+        reader := bufio.NewReader(os.Stdin)
+        fmt.Println("Enter an optional passphrase to protect the seed (leave empty for none)")
+        passphrase, _ := reader.ReadString('\n')
+        passphrase     = strings.Trim(passphrase, "\n")
+
+        masterKey, err := newMasterKey(mnemonicToSeed(mnemonic, passphrase))
+        if err != nil {
+            fmt.Println(err)
+            listenForUserInput(n)
+            break
+        }
+        n.setMasterKey(masterKey)
+        fmt.Println("Write down these words in order, they are the only backup of your keys:")
+        fmt.Printf("  %v\n", mnemonic)
+        fmt.Println("Enter 'derive' to create keys from this seed.")
+        fmt.Println()
+        listenForUserInput(n)
+    case "restore":
+        reader := bufio.NewReader(os.Stdin)
+
+        // ask for mnemonic
+        fmt.Println("Enter your seed words separated by spaces")
+        mnemonic, err := reader.ReadString('\n')
+        if (err != nil || mnemonic == "\n") {
+            fmt.Println(err)
+            fmt.Println("Please enter your seed words. Enter 'restore' to begin again.")
+            listenForUserInput(n)
+            break
+        }
+        if _, err := mnemonicToEntropy(mnemonic); err != nil {
+            fmt.Println(err)
+            fmt.Println("Please enter valid seed words. Enter 'restore' to begin again.")
+            listenForUserInput(n)
+            break
+        }
+
+        // ask for passphrase
+        fmt.Println("Enter the passphrase for the seed (leave empty for none)")
+        passphrase, _ := reader.ReadString('\n')
+        passphrase     = strings.Trim(passphrase, "\n")
+
+        masterKey, err := newMasterKey(mnemonicToSeed(mnemonic, passphrase))
+        if err != nil {
+            fmt.Println(err)
+            listenForUserInput(n)
+            break
+        }
+        n.setMasterKey(masterKey)
+        fmt.Println("Seed restored. Enter 'derive' to recreate your keys.")
+        fmt.Println()
+        listenForUserInput(n)
+    case "derive":
+        if n.getMasterKey() == nil {
+            fmt.Println("You must enter 'newseed' or 'restore' before deriving keys")
+            listenForUserInput(n)
+            break
+        }
+        reader := bufio.NewReader(os.Stdin)
+
+        // ask for derivation path
+        fmt.Println("Enter the derivation path of the key, eg. m/0'/1 (leave empty for m/0')")
+        path, _ := reader.ReadString('\n')
+        path     = strings.Trim(path, "\n")
+        if path == "" { path = "m/0'" }
+
+        key, err := n.getMasterKey().derivePath(path)
+        if err != nil {
+            fmt.Println(err)
+            fmt.Println("Please enter a valid path. Enter 'derive' to begin again.")
+            listenForUserInput(n)
+            break
+        }
+        keys := key.keypair()
+        fmt.Printf("Path: %v\n", path)
+        fmt.Printf("Public: %v\nPrivate: %v\n", string(keys.Public), string(keys.Private))
+        fmt.Println()
+        listenForUserInput(n)
     case "upload":
         reader := bufio.NewReader(os.Stdin) //constantly be reading in from std in
         
@@ -70,7 +196,7 @@ func handleUserInput(input string, blockWrapperChannel chan *BlockWrapper, packe
         if (err != nil || filePath == "\n") {
             fmt.Println(err)
             fmt.Println("Please enter a valid filepath. Enter 'upload' to begin again.")
-            listenForUserInput(blockWrapperChannel, packetChannel, n)
+            listenForUserInput(n)
             break
         }
         filePath = strings.Trim(filePath, "\n")
@@ -80,7 +206,7 @@ func handleUserInput(input string, blockWrapperChannel chan *BlockWrapper, packe
         publicKey, err := reader.ReadString('\n')
         if (err != nil || publicKey == "\n") {
             fmt.Println(err)
-            listenForUserInput(blockWrapperChannel, packetChannel, n)        }
+            listenForUserInput(n)        }
         publicKey = strings.Trim(publicKey, "\n")
 
         // ask for private key
@@ -94,23 +220,469 @@ func handleUserInput(input string, blockWrapperChannel chan *BlockWrapper, packe
         privateKey = strings.Trim(privateKey, "\n")
         keyPair := Keypair{Public: []byte(publicKey), Private: []byte(privateKey)}
 
+        // directories are anchored as a manifest of all their files
+        if info, err := os.Stat(filePath); err == nil && info.IsDir() {
+            fmt.Println("Enter a short memo to sign with the directory, or leave empty")
+            memo, _ := reader.ReadString('\n')
+
+            manifest, err := buildManifest(filePath, defaultHashAlgorithm, os.Stdout)
+            if err != nil {
+                fmt.Println(err)
+                fmt.Println("Unable to hash directory. Enter 'upload' to begin again.")
+                listenForUserInput(n)
+                break
+            }
+            manifestPath := strings.TrimRight(filePath, "/") + ".manifest.json"
+            if err := writeManifest(manifest, manifestPath); err != nil {
+                fmt.Println(err)
+            }
+            fmt.Printf("Hashed %v files, keep %v to prove any of them later with 'verifyfile'\n", len(manifest.Files), manifestPath)
+
+            packet := createManifestPacket(manifest, keyPair, strings.TrimSpace(memo))
+            fmt.Printf("This the hash of your packet: %v\n", formatHash(packet.Hash))
+            if verifyPacket(packet){
+                fmt.Println("Your packet is valid, sending out to network!")
+                n.packetChannel <- packet
+            } else {
+                fmt.Println("Your packet was invalid, and will not be sent to blockchain")
+            }
+            listenForUserInput(n)
+            break
+        }
+
+        // ask for optional metadata
+        fmt.Println("Enter the hash algorithm to record with the document (sha256, sha512, sha3-256 or blake2b-256), or leave empty for a plain sha256 packet")
+        algorithm, _ := reader.ReadString('\n')
+        algorithm     = strings.TrimSpace(algorithm)
+        memo := ""
+        if algorithm != "" {
+            if _, known := hashAlgorithms[algorithm]; !known {
+                fmt.Println("Unknown hash algorithm. Enter 'upload' to begin again.")
+                listenForUserInput(n)
+                break
+            }
+            fmt.Printf("Enter a short memo to sign with the document (at most %v characters), or leave empty\n", maxMemoLength)
+            memo, _ = reader.ReadString('\n')
+            memo    = strings.TrimSpace(memo)
+        }
+
         // create packet and print packet hash to user
         packet := createPacket(filePath, keyPair)
-        packetHashHex := hex.EncodeToString(packet.Hash)
+        if algorithm != "" {
+            packet = createPacketWithMetadata(filePath, keyPair, algorithm, memo)
+        }
+        packetHashHex := formatHash(packet.Hash)
         fmt.Printf("This the hash of your packet: %v\n", packetHashHex)
 
         // check validity of package
-        if verifyPacketSignature(packet){
+        if verifyPacket(packet){
             fmt.Println("Your packet is valid, sending out to network!")
             
             // send to packet channel
-            packetChannel <- packet            
+            n.packetChannel <- packet            
         } else {
             fmt.Println("Your packet was invalid, and will not be sent to blockchain")
         }
         
         // go back user input as normal
-        listenForUserInput(blockWrapperChannel, packetChannel, n)
+        listenForUserInput(n)
+    case "cosign":
+        reader := bufio.NewReader(os.Stdin)
+
+        // the co-owners must agree on the file, threshold and owner list before signing
+        filePath, threshold, owners, err := readMultiSigTerms(reader)
+        if err != nil {
+            fmt.Println(err)
+            fmt.Println("Enter 'cosign' to begin again.")
+            listenForUserInput(n)
+            break
+        }
+
+        // ask for keys
+        fmt.Println("Enter your public key, which must be one of the co-owners")
+        publicKey, _ := reader.ReadString('\n')
+        publicKey     = strings.Trim(publicKey, "\n")
+        fmt.Println("Enter your private key to sign the document")
+        privateKey, _ := reader.ReadString('\n')
+        privateKey     = strings.Trim(privateKey, "\n")
+        keyPair := Keypair{Public: []byte(publicKey), Private: []byte(privateKey)}
+
+        if !packetHasOwner(Packet{Owners: owners}, keyPair.Public) {
+            fmt.Println("Your public key is not one of the co-owners. Enter 'cosign' to begin again.")
+            listenForUserInput(n)
+            break
+        }
+
+        documentHash, _, err := hashFile(filePath, defaultHashAlgorithm, os.Stdout)
+        if err != nil {
+            fmt.Println(err)
+            fmt.Println("Enter 'cosign' to begin again.")
+            listenForUserInput(n)
+            break
+        }
+        signature := signMultiSig(documentHash, threshold, owners, keyPair)
+        if !SignatureVerify(keyPair.Public, signature, multiSigDigest(documentHash, threshold, owners)) {
+            fmt.Println("Your public and private keys do not match. Enter 'cosign' to begin again.")
+            listenForUserInput(n)
+            break
+        }
+        fmt.Println("Send this partial signature to whoever will enter 'multiupload':")
+        fmt.Printf("  %v\n", string(signature))
+        fmt.Println()
+        listenForUserInput(n)
+    case "multiupload":
+        reader := bufio.NewReader(os.Stdin)
+
+        filePath, threshold, owners, err := readMultiSigTerms(reader)
+        if err != nil {
+            fmt.Println(err)
+            fmt.Println("Enter 'multiupload' to begin again.")
+            listenForUserInput(n)
+            break
+        }
+
+        // collect the partial signatures made with 'cosign'
+        signatures := [][]byte{}
+        for i, owner := range owners {
+            fmt.Printf("Enter the partial signature of co-owner %v (%v), or leave empty if they did not sign\n", i+1, string(owner))
+            signature, _ := reader.ReadString('\n')
+            signatures    = append(signatures, []byte(strings.TrimSpace(signature)))
+        }
+
+        documentHash, _, err := hashFile(filePath, defaultHashAlgorithm, os.Stdout)
+        if err != nil {
+            fmt.Println(err)
+            fmt.Println("Enter 'multiupload' to begin again.")
+            listenForUserInput(n)
+            break
+        }
+        packet := createMultiSigPacket(documentHash, threshold, owners, signatures)
+        packetHashHex := formatHash(packet.Hash)
+        fmt.Printf("This the hash of your packet: %v\n", packetHashHex)
+
+        if verifyPacket(packet){
+            fmt.Println("Your packet is valid, sending out to network!")
+            n.packetChannel <- packet
+        } else {
+            fmt.Printf("Your packet needs %v valid signatures from its co-owners, and will not be sent to blockchain\n", threshold)
+        }
+        listenForUserInput(n)
+    case "rotate", "revoke":
+        reader := bufio.NewReader(os.Stdin)
+
+        // ask for the key to retire
+        fmt.Println("Enter the public key you wish to retire")
+        publicKey, err := reader.ReadString('\n')
+        if (err != nil || publicKey == "\n") {
+            fmt.Println(err)
+            fmt.Printf("Please enter a valid publicKey. Enter '%v' to begin again.\n", arg0)
+            listenForUserInput(n)
+            break
+        }
+        publicKey = strings.Trim(publicKey, "\n")
+
+        fmt.Println("Enter its private key to sign the record")
+        privateKey, _ := reader.ReadString('\n')
+        privateKey     = strings.Trim(privateKey, "\n")
+        keyPair := Keypair{Public: []byte(publicKey), Private: []byte(privateKey)}
+
+        kind      := keyRevocation
+        successor := []byte{}
+        if arg0 == "rotate" {
+            kind = keyRotation
+            fmt.Println("Enter the public key of the successor key")
+            successorKey, _ := reader.ReadString('\n')
+            successor        = []byte(strings.Trim(successorKey, "\n"))
+        }
+
+        // ask for the height after which the key is retired
+        currentHeight := int(n.getBlockchain().getLastBlock().Index)
+        fmt.Printf("Enter the block height after which the key is retired, up to %v, or leave empty to retire it from the next block (current height is %v)\n", currentHeight+1, currentHeight)
+        heightString, _ := reader.ReadString('\n')
+        heightString     = strings.TrimSpace(heightString)
+        height := 0
+        if heightString != "" {
+            height, err = strconv.Atoi(heightString)
+            if err != nil || height < 1 || height > currentHeight+1 {
+                fmt.Printf("Please enter a valid height. Enter '%v' to begin again.\n", arg0)
+                listenForUserInput(n)
+                break
+            }
+        }
+
+        record := createKeyRecord(kind, keyPair, successor, uint32(height))
+        if verifyKeyRecord(record){
+            fmt.Println("Your key record is valid, sending out to network!")
+            n.recordChannel <- newRecord(keyRecordType, record)
+        } else {
+            fmt.Println("Your key record was invalid, and will not be sent to blockchain")
+        }
+        listenForUserInput(n)
+    case "claim", "annotate":
+        reader := bufio.NewReader(os.Stdin)
+
+        // ask for keys
+        fmt.Println("Enter your public key")
+        publicKey, err := reader.ReadString('\n')
+        if (err != nil || publicKey == "\n") {
+            fmt.Println(err)
+            fmt.Printf("Please enter a valid publicKey. Enter '%v' to begin again.\n", arg0)
+            listenForUserInput(n)
+            break
+        }
+        publicKey = strings.Trim(publicKey, "\n")
+        fmt.Println("Enter your private key to sign the record")
+        privateKey, _ := reader.ReadString('\n')
+        privateKey     = strings.Trim(privateKey, "\n")
+        keyPair := Keypair{Public: []byte(publicKey), Private: []byte(privateKey)}
+
+        var record Record
+        var valid  bool
+        if arg0 == "claim" {
+            fmt.Printf("Enter the name you want associated with this key (at most %v characters)\n", maxClaimNameLength)
+            name, _ := reader.ReadString('\n')
+            claim   := createIdentityClaim(keyPair, strings.TrimSpace(name))
+            record   = newRecord(identityRecord, claim)
+            valid    = verifyIdentityClaim(claim)
+        } else {
+            fmt.Println("Enter the hash (in hex) of the document to annotate, or leave empty for none")
+            subjectHex, _ := reader.ReadString('\n')
+            subject, err  := hex.DecodeString(strings.TrimSpace(subjectHex))
+            if err != nil {
+                fmt.Println(err)
+                fmt.Println("Please enter a valid hash. Enter 'annotate' to begin again.")
+                listenForUserInput(n)
+                break
+            }
+            fmt.Printf("Enter your note (at most %v characters)\n", maxAnnotationLength)
+            text, _    := reader.ReadString('\n')
+            annotation := createAnnotation(keyPair, subject, strings.TrimSpace(text))
+            record      = newRecord(annotationRecord, annotation)
+            valid       = verifyAnnotation(annotation)
+        }
+
+        if valid {
+            fmt.Println("Your record is valid, sending out to network!")
+            n.recordChannel <- record
+        } else {
+            fmt.Println("Your record was invalid, and will not be sent to blockchain")
+        }
+        listenForUserInput(n)
+    case "authority":
+        if _, poa := consensus.(poaEngine); !poa {
+            fmt.Println("Authorities only seal blocks with consensus.engine poa, this node mines them")
+            listenForUserInput(n)
+            break
+        }
+        reader := bufio.NewReader(os.Stdin)
+
+        fmt.Println("Enter 'add' to add an authority, or 'remove' to remove one")
+        kindString, _ := reader.ReadString('\n')
+        kindString     = strings.TrimSpace(kindString)
+        if kindString != "add" && kindString != "remove" {
+            fmt.Println("Please enter add or remove. Enter 'authority' to begin again.")
+            listenForUserInput(n)
+            break
+        }
+        kind := authorityAdd
+        if kindString == "remove" {
+            kind = authorityRemove
+        }
+        fmt.Println("Enter the public key of the authority")
+        key, _ := reader.ReadString('\n')
+
+        // more than half of the current authorities must sign the change
+        authorities, epoch, _ := authoritiesAfter(n.getBlockchain().Blocks)
+        change := AuthorityChange{Kind: kind, Key: []byte(strings.TrimSpace(key)), Epoch: epoch}
+        for i, authority := range authorities {
+            fmt.Printf("Enter the private key of authority %v (%v), or leave empty if they do not sign\n", i+1, string(authority))
+            privateKey, _ := reader.ReadString('\n')
+            if privateKey = strings.TrimSpace(privateKey); privateKey != "" {
+                change = signAuthorityChange(change, Keypair{Public: authority, Private: []byte(privateKey)})
+            }
+        }
+
+        if _, _, approved := applyAuthorityChange(authorities, epoch, change); verifyAuthorityChange(change) && approved {
+            fmt.Println("Your authority change is valid, sending out to network!")
+            n.recordChannel <- newRecord(authorityRecord, change)
+        } else {
+            fmt.Printf("Your authority change needs valid signatures from more than %v of the %v authorities, and will not be sent to blockchain\n", len(authorities)/2, len(authorities))
+        }
+        listenForUserInput(n)
+    case "verifyfile":
+        reader := bufio.NewReader(os.Stdin)
+
+        // ask for manifest
+        fmt.Println("Enter the name of the manifest written when the directory was uploaded")
+        manifestPath, _ := reader.ReadString('\n')
+        manifest, err   := readManifest(strings.TrimSpace(manifestPath))
+        if err != nil {
+            fmt.Println(err)
+            fmt.Println("Please enter a valid manifest. Enter 'verifyfile' to begin again.")
+            listenForUserInput(n)
+            break
+        }
+
+        // ask for the file, by its path inside the uploaded directory
+        fmt.Println("Enter the path of the file relative to the uploaded directory")
+        relativePath, _ := reader.ReadString('\n')
+        relativePath     = strings.TrimSpace(relativePath)
+        entry, found    := manifest.findEntry(relativePath)
+        if !found {
+            fmt.Println("That file is not in the manifest. Enter 'verifyfile' to begin again.")
+            listenForUserInput(n)
+            break
+        }
+        fmt.Println("Enter where that file is now, or leave empty to only check the manifest")
+        currentPath, _ := reader.ReadString('\n')
+        currentPath     = strings.TrimSpace(currentPath)
+        if currentPath != "" {
+            fileHash, _, err := hashFile(currentPath, manifest.Algorithm, os.Stdout)
+            if err != nil || string(fileHash) != string(entry.Hash) {
+                fmt.Println("The file does not match the one in the manifest")
+                listenForUserInput(n)
+                break
+            }
+        }
+        if !verifyManifestEntry(entry, manifest.Root) {
+            fmt.Println("The manifest proof for this file is invalid")
+            listenForUserInput(n)
+            break
+        }
+
+        // ask for the owner, to find the manifest root on the blockchain
+        fmt.Println("Enter the public key that uploaded the directory")
+        publicKey, _ := reader.ReadString('\n')
+        publicKey     = strings.TrimSpace(publicKey)
+        if height, found := n.getBlockchain().findPacketBlockIndex(manifest.Root, []byte(publicKey)); found {
+            fmt.Printf("%v is part of the directory anchored in block #%v with root %v\n", entry.Path, height, formatHash(manifest.Root))
+            fmt.Println(n.getBlockchain().describeKeyStatus([]byte(publicKey), height))
+        } else {
+            fmt.Printf("The proof is valid, but root %v is not on the blockchain with that public key\n", formatHash(manifest.Root))
+        }
+        listenForUserInput(n)
+    case "batch":
+        reader := bufio.NewReader(os.Stdin)
+
+        // ask for the list of documents
+        fmt.Println("Enter the name of a file listing the documents to upload, one per line")
+        listPath, _ := reader.ReadString('\n')
+        listPath       = strings.TrimSpace(listPath)
+        paths, err    := readBatchList(listPath)
+        if err != nil {
+            fmt.Println(err)
+            fmt.Println("Please enter a valid list. Enter 'batch' to begin again.")
+            listenForUserInput(n)
+            break
+        }
+
+        // ask for keys
+        fmt.Println("Enter your public key to associate with the documents")
+        publicKey, _ := reader.ReadString('\n')
+        fmt.Println("Enter your private key to sign the batch")
+        privateKey, _ := reader.ReadString('\n')
+        keyPair := Keypair{Public: []byte(strings.TrimSpace(publicKey)), Private: []byte(strings.TrimSpace(privateKey))}
+
+        fmt.Println("Enter a short memo to sign with the batch, or leave empty")
+        memo, _ := reader.ReadString('\n')
+
+        batch, err := buildBatch(paths, os.Stdout)
+        if err != nil {
+            fmt.Println(err)
+            fmt.Println("Unable to hash the documents. Enter 'batch' to begin again.")
+            listenForUserInput(n)
+            break
+        }
+        batchPath := listPath + ".batch.json"
+        if err := writeBatch(batch, batchPath); err != nil {
+            fmt.Println(err)
+        }
+        fmt.Printf("Hashed %v documents, keep %v to prove any of them later with 'lookup'\n", len(batch.Documents), batchPath)
+
+        packet := createBatchPacket(batch, keyPair, strings.TrimSpace(memo))
+        fmt.Printf("This the hash of your packet: %v\n", formatHash(packet.Hash))
+        if verifyPacket(packet){
+            fmt.Println("Your packet is valid, sending out to network!")
+            n.packetChannel <- packet
+        } else {
+            fmt.Println("Your packet was invalid, and will not be sent to blockchain")
+        }
+        listenForUserInput(n)
+    case "verifyproof":
+        reader := bufio.NewReader(os.Stdin)
+
+        fmt.Println("Enter the name of the inclusion proof file")
+        proofPath, _ := reader.ReadString('\n')
+        proof, err   := readInclusionProof(strings.TrimSpace(proofPath))
+        if err != nil {
+            fmt.Println(err)
+            fmt.Println("Please enter a valid proof. Enter 'verifyproof' to begin again.")
+            listenForUserInput(n)
+            break
+        }
+        if n.getBlockchain().verifyInclusionProof(proof) {
+            fmt.Printf("Document %v was anchored in block #%v\n", formatHash(proof.DocumentHash), proof.BlockIndex)
+        } else if verifyInclusionProof(proof) {
+            fmt.Printf("The proof is valid, but block #%v is not part of your blockchain. Enter 'getchain' to update it.\n", proof.BlockIndex)
+        } else {
+            fmt.Println("The proof is invalid")
+        }
+        listenForUserInput(n)
+    case "export-chain":
+        reader := bufio.NewReader(os.Stdin)
+
+        fmt.Println("Enter the name of the file to export your blockchain to")
+        chainPath, _ := reader.ReadString('\n')
+        chainPath     = strings.TrimSpace(chainPath)
+        blockchain   := n.getBlockchain()
+        if err := exportChain(chainPath, blockchain); err != nil {
+            fmt.Println(err)
+            fmt.Println("Your blockchain was not exported. Enter 'export-chain' to begin again.")
+            listenForUserInput(n)
+            break
+        }
+        fmt.Printf("Exported %v blocks to %v\n", len(blockchain.Blocks), chainPath)
+        listenForUserInput(n)
+    case "import-chain":
+        reader := bufio.NewReader(os.Stdin)
+
+        fmt.Println("Enter the name of the chain file to import")
+        chainPath, _ := reader.ReadString('\n')
+        imported, err := importChain(n, strings.TrimSpace(chainPath))
+        fmt.Printf("Imported %v blocks, your blockchain now ends at block #%v\n", imported, n.getBlockchain().getLastBlock().Index)
+        if err != nil {
+            fmt.Println(err)
+            fmt.Println("The import stopped there. Enter 'import-chain' to carry on once the file is fixed.")
+        }
+        listenForUserInput(n)
+    case "checkchain":
+        blockchain := n.getBlockchain()
+        if err := blockchain.checkChain(); err != nil {
+            fmt.Println("Your blockchain is damaged from there on:", err)
+        } else {
+            fmt.Printf("Checked %v blocks, your blockchain is intact\n", len(blockchain.Blocks))
+        }
+        if dataDir := n.config.Storage.DataDir; dataDir != "" {
+            saved, err := loadBlockchain(dataDir)
+            if err != nil {
+                fmt.Println("Unable to read the blockchain saved in", dataDir)
+                fmt.Println(err)
+            } else if len(saved.Blocks) == 0 {
+                fmt.Printf("There is no blockchain saved in %v yet\n", dataDir)
+            } else if err := saved.checkChain(); err != nil {
+                fmt.Printf("The blockchain saved in %v is damaged from there on: %v\n", dataDir, err)
+            } else {
+                fmt.Printf("Checked the %v blocks saved in %v, they are intact\n", len(saved.Blocks), dataDir)
+            }
+        }
+        fmt.Println()
+        listenForUserInput(n)
+    case "reindex":
+        stats := n.reindex()
+        fmt.Printf("Rebuilt the index of %v blocks: %v were missing from it and %v were not on your blockchain\n", stats.Blocks, stats.Missing, stats.Stale)
+        fmt.Printf("Dropped %v packets and %v records waiting to be mined that were already on your blockchain\n", stats.DroppedPackets, stats.DroppedRecords)
+        fmt.Println()
+        listenForUserInput(n)
     case "lookup":
         reader := bufio.NewReader(os.Stdin) //constantly be reading in from std in
         
@@ -120,7 +692,7 @@ func handleUserInput(input string, blockWrapperChannel chan *BlockWrapper, packe
         if (err != nil || packetHash == "\n") {
             fmt.Println(err)
             fmt.Println("Please enter a valid filepath. Enter 'lookup' to begin again.")
-            listenForUserInput(blockWrapperChannel, packetChannel, n)
+            listenForUserInput(n)
             break
         }
         packetHash      = strings.Trim(packetHash, "\n")
@@ -128,7 +700,7 @@ func handleUserInput(input string, blockWrapperChannel chan *BlockWrapper, packe
         if err != nil {
             fmt.Println(err)
             fmt.Println("Please enter valid packetHash. Enter 'lookup' to begin again.")
-            listenForUserInput(blockWrapperChannel, packetChannel, n)
+            listenForUserInput(n)
             break
         }
 
@@ -138,28 +710,107 @@ func handleUserInput(input string, blockWrapperChannel chan *BlockWrapper, packe
         if (err != nil || publicKey == "\n") {
             fmt.Println(err)
             fmt.Println("Please enter a valid publicKey. Enter 'lookup' to begin again.")
-            listenForUserInput(blockWrapperChannel, packetChannel, n)
+            listenForUserInput(n)
             break
         }
         publicKey       = strings.Trim(publicKey, "\n")
         publicKeyBytes := []byte(publicKey)
 
         // return whether or not the public key validates this packet hash
-        packet := n.blockchain.findPacketByHashAndPublicKey(packetHashBytes, publicKeyBytes)
+        packet := n.getBlockchain().findPacketByHashAndPublicKey(packetHashBytes, publicKeyBytes)
         emptyPacket := Packet{}
         if equalPackets(packet, emptyPacket) {
             fmt.Println("The document you requested does not exist with that combination hash and public key")
+
+            // the document may have been anchored as part of a batch
+            fmt.Println("If it was uploaded in a batch, enter the batch file written by 'batch', or leave empty")
+            batchPath, _ := reader.ReadString('\n')
+            batchPath     = strings.TrimSpace(batchPath)
+            if batchPath != "" {
+                batch, err := readBatch(batchPath)
+                if err != nil {
+                    fmt.Println(err)
+                    listenForUserInput(n)
+                    break
+                }
+                entry, found := batch.findDocument(packetHashBytes)
+                proof, valid := n.getBlockchain().createBatchInclusionProof(entry, batch.Root, publicKeyBytes)
+                if found && valid {
+                    fmt.Printf("Found the document in the batch with root %v, anchored in block #%v\n", formatHash(batch.Root), proof.BlockIndex)
+                    fmt.Println(n.getBlockchain().describeKeyStatus(publicKeyBytes, proof.BlockIndex))
+                    saveInclusionProof(reader, proof)
+                } else {
+                    fmt.Println("The document is not part of an anchored batch with that public key")
+                }
+            }
         } else {
             fmt.Println("Found the packet you were looking for:")
-            fmt.Println(packet)
+            fmt.Print(formatPacket(packet))
+            packetHeight, _ := n.getBlockchain().findPacketBlockIndex(packetHashBytes, publicKeyBytes)
+            fmt.Println(n.getBlockchain().describeKeyStatus(publicKeyBytes, packetHeight))
+            for _, name := range n.getBlockchain().findIdentityClaims(publicKeyBytes) {
+                fmt.Printf("The owner of this key claims to be %q\n", name)
+            }
+            for _, annotation := range n.getBlockchain().findAnnotations(packetHashBytes) {
+                fmt.Printf("Note by %v: %q\n", string(annotation.Author), annotation.Text)
+            }
+            if proof, found := n.getBlockchain().createInclusionProof(packetHashBytes, publicKeyBytes); found {
+                saveInclusionProof(reader, proof)
+            }
         }
-        listenForUserInput(blockWrapperChannel, packetChannel, n)
+        listenForUserInput(n)
     case "help":
         showNodeHelp()
         fmt.Println()
-        listenForUserInput(blockWrapperChannel, packetChannel, n)
+        listenForUserInput(n)
     default:
         fmt.Println("Enter 'help' for options.")
-        listenForUserInput(blockWrapperChannel, packetChannel, n)
+        listenForUserInput(n)
+    }
+}
+
+// asks for the document, threshold and co-owners shared by 'cosign' and 'multiupload'
+func readMultiSigTerms(reader *bufio.Reader) (filePath string, threshold uint32, owners [][]byte, err error) {
+    fmt.Println("Enter the name of the jointly owned file")
+    filePath, err = reader.ReadString('\n')
+    if err != nil {
+        return "", 0, nil, err
     }
-}
\ No newline at end of file
+    filePath = strings.Trim(filePath, "\n")
+
+    fmt.Println("Enter the public keys of all co-owners, in the same order on every machine, separated by spaces")
+    ownerList, err := reader.ReadString('\n')
+    if err != nil {
+        return "", 0, nil, err
+    }
+    for _, owner := range strings.Fields(ownerList) {
+        owners = append(owners, []byte(owner))
+    }
+
+    fmt.Println("Enter how many co-owners must sign")
+    thresholdString, err := reader.ReadString('\n')
+    if err != nil {
+        return "", 0, nil, err
+    }
+    thresholdInt, err := strconv.Atoi(strings.TrimSpace(thresholdString))
+    if err != nil || thresholdInt < 1 || thresholdInt > len(owners) {
+        return "", 0, nil, fmt.Errorf("the number of signatures must be between 1 and %v", len(owners))
+    }
+
+    return filePath, uint32(thresholdInt), owners, nil
+}
+
+// offers to write an inclusion proof that can be checked with 'verifyproof'
+func saveInclusionProof(reader *bufio.Reader, proof InclusionProof) {
+    fmt.Println("Enter a file name to save an inclusion proof of this document, or leave empty")
+    proofPath, _ := reader.ReadString('\n')
+    proofPath     = strings.TrimSpace(proofPath)
+    if proofPath == "" {
+        return
+    }
+    if err := writeInclusionProof(proof, proofPath); err != nil {
+        fmt.Println(err)
+        return
+    }
+    fmt.Printf("Saved inclusion proof to %v\n", proofPath)
+}
@@ -20,14 +20,22 @@ func GenerateNewKeypair() *Keypair {
 
 	pk, _ := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
 
-	b := bigJoin(28, pk.PublicKey.X, pk.PublicKey.Y)
+	kp := keypairFromPrivateKey(pk.D)
 
-	public := base58.EncodeBig([]byte{}, b)
-	private := base58.EncodeBig([]byte{}, pk.D)
+	return &kp
+}
 
-	kp := Keypair{Public: public, Private: private}
+// encodes the private scalar d and its public point the same way as GenerateNewKeypair
+func keypairFromPrivateKey(d *big.Int) Keypair {
 
-	return &kp
+	x, y := elliptic.P224().ScalarBaseMult(d.Bytes())
+
+	b := bigJoin(28, x, y)
+
+	public := base58.EncodeBig([]byte{}, b)
+	private := base58.EncodeBig([]byte{}, d)
+
+	return Keypair{Public: public, Private: private}
 }
 
 func (k *Keypair) Sign(hash []byte) ([]byte, error) {
@@ -42,7 +50,7 @@ func (k *Keypair) Sign(hash []byte) ([]byte, error) {
 	pub := splitBig(b, 2)
 	x, y := pub[0], pub[1]
 
-	key := ecdsa.PrivateKey{ecdsa.PublicKey{elliptic.P224(), x, y}, d}
+	key := ecdsa.PrivateKey{PublicKey: ecdsa.PublicKey{Curve: elliptic.P224(), X: x, Y: y}, D: d}
 
 	r, s, _ := ecdsa.Sign(rand.Reader, &key, hash)
 
@@ -59,7 +67,7 @@ func SignatureVerify(publicKey, sig, hash []byte) bool {
 	sigg := splitBig(b, 2)
 	r, s := sigg[0], sigg[1]
 
-	pub := ecdsa.PublicKey{elliptic.P224(), x, y}
+	pub := ecdsa.PublicKey{Curve: elliptic.P224(), X: x, Y: y}
 
 	return ecdsa.Verify(&pub, hash, r, s)
 }
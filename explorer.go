@@ -0,0 +1,171 @@
+package main
+
+import (
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+/*
+explorer.go is the block explorer, a small web page served by the API at
+/ that lists the latest blocks, shows a block's packets and records,
+searches for packets by document hash or owner and shows the node's
+peers.  The page, in explorer/, is built into the binary and reads the
+JSON endpoints below:
+
+  GET /api/blocks?limit=n    the last n blocks, newest first
+  GET /api/blocks/{id}       one block, by index or hash in hex
+  GET /api/packets?hash=&owner=  packets anchoring a document hash (hex) or owned by a key (base58)
+  GET /api/peers             the node, its connections and mempool
+
+Blocks and packets are shown as --format json prints them, see format.go.
+*/
+
+//go:embed explorer
+var explorerAssets embed.FS
+
+const defaultExplorerBlocks = 20
+const maxExplorerBlocks     = 100
+
+// a block in the list of latest blocks
+type blockSummary struct {
+	Index    uint32 `json:"index"`
+	Hash     string `json:"hash"`
+	PrevHash string `json:"prev_hash"`
+	Packets  int    `json:"packets"`
+	Records  int    `json:"records"`
+	Signer   string `json:"signer,omitempty"`
+}
+
+type foundPacket struct {
+	Block  *uint32    `json:"block"` // null while waiting to be mined
+	Packet packetView `json:"packet"`
+}
+
+type peerView struct {
+	ID     int    `json:"id"`
+	Remote string `json:"remote"`
+	PeerID string `json:"peer_id,omitempty"` // only known over the tls transport
+}
+
+type nodeStatus struct {
+	Address        string     `json:"address"`
+	ID             string     `json:"id"` // only known over the tls transport
+	Seed           string     `json:"seed"`
+	Height         uint32     `json:"height"`
+	Tip            string     `json:"tip"`
+	Peers          []peerView `json:"peers"`
+	MempoolPackets int        `json:"mempool_packets"`
+	MempoolRecords int        `json:"mempool_records"`
+}
+
+func (n *Node) registerExplorer(mux *http.ServeMux) {
+	assets, _ := fs.Sub(explorerAssets, "explorer")
+	mux.Handle("GET /", http.FileServer(http.FS(assets)))
+	mux.HandleFunc("GET /api/blocks", n.handleBlocks)
+	mux.HandleFunc("GET /api/blocks/{id}", n.handleBlock)
+	mux.HandleFunc("GET /api/packets", n.handlePackets)
+	mux.HandleFunc("GET /api/peers", n.handlePeers)
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		apiLog.Debug("unable to write response", "remote", r.RemoteAddr, "err", err)
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	writeJSON(w, r, status, map[string]string{"error": message})
+}
+
+func (n *Node) handleBlocks(w http.ResponseWriter, r *http.Request) {
+	limit := defaultExplorerBlocks
+	if r.URL.Query().Has("limit") {
+		parsed, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil || parsed < 1 {
+			writeJSONError(w, r, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+		limit = min(parsed, maxExplorerBlocks)
+	}
+
+	blockchain := n.getBlockchain()
+	summaries  := []blockSummary{}
+	for i := len(blockchain.Blocks) - 1; i >= 0 && len(summaries) < limit; i-- {
+		block := blockchain.Blocks[i]
+		summaries = append(summaries, blockSummary{Index:    block.Index,
+		                                           Hash:     formatHash(block.Hash),
+		                                           PrevHash: formatHash(block.PrevHash),
+		                                           Packets:  len(blockPackets(block)),
+		                                           Records:  len(block.Records),
+		                                           Signer:   formatKey(block.Signer)})
+	}
+	writeJSON(w, r, http.StatusOK, summaries)
+}
+
+func (n *Node) handleBlock(w http.ResponseWriter, r *http.Request) {
+	block, found := n.getBlockchain().findBlock(r.PathValue("id"))
+	if !found {
+		writeJSONError(w, r, http.StatusNotFound, "no block with that index or hash")
+		return
+	}
+	writeJSON(w, r, http.StatusOK, newBlockView(block))
+}
+
+func (n *Node) handlePackets(w http.ResponseWriter, r *http.Request) {
+	hash, err := hex.DecodeString(r.URL.Query().Get("hash"))
+	owner     := []byte(r.URL.Query().Get("owner"))
+	if err != nil || (len(hash) == 0 && len(owner) == 0) {
+		writeJSONError(w, r, http.StatusBadRequest, "search by a document hash in hex, an owner's public key, or both")
+		return
+	}
+	matches := func(packet Packet) bool {
+		return (len(hash) == 0 || string(packet.Hash) == string(hash)) && (len(owner) == 0 || packetHasOwner(packet, owner))
+	}
+
+	found := []foundPacket{}
+	for _, block := range n.getBlockchain().Blocks {
+		for _, packet := range blockPackets(block) {
+			if matches(packet) {
+				index := block.Index
+				found  = append(found, foundPacket{Block: &index, Packet: newPacketView(packet)})
+			}
+		}
+	}
+	for _, packet := range n.getCurPacketList() {
+		if matches(packet) {
+			found = append(found, foundPacket{Packet: newPacketView(packet)})
+		}
+	}
+	writeJSON(w, r, http.StatusOK, found)
+}
+
+func (n *Node) handlePeers(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, http.StatusOK, n.getStatus())
+}
+
+// the node's status, as the explorer shows it
+func (n *Node) getStatus() nodeStatus {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	lastBlock := n.blockchain.getLastBlock()
+	status    := nodeStatus{Address:        n.address,
+	                        ID:             n.id,
+	                        Seed:           n.seed,
+	                        Height:         lastBlock.Index,
+	                        Tip:            formatHash(lastBlock.Hash),
+	                        Peers:          []peerView{},
+	                        MempoolPackets: len(n.curPacketList),
+	                        MempoolRecords: len(n.curRecordList)}
+	for conn, id := range n.connections {
+		status.Peers = append(status.Peers, peerView{ID: id, Remote: conn.RemoteAddr().String(), PeerID: peerID(conn)})
+	}
+	sort.Slice(status.Peers, func(i, j int) bool { return status.Peers[i].ID < status.Peers[j].ID })
+	return status
+}
@@ -0,0 +1,160 @@
+// the block explorer: a page per location hash, #/ for the latest blocks and
+// the node's peers, #/block/<index or hash> for a block, #/search/<query>
+"use strict";
+
+const view = document.getElementById("view");
+
+function escape(text) {
+  const div = document.createElement("div");
+  div.textContent = String(text);
+  return div.innerHTML;
+}
+
+async function api(path) {
+  const response = await fetch(path);
+  const body     = await response.json();
+  if (!response.ok) {
+    throw new Error(body.error || response.statusText);
+  }
+  return body;
+}
+
+function blockLink(id, text) {
+  return `<a href="#/block/${encodeURIComponent(id)}" class="hex">${escape(text === undefined ? id : text)}</a>`;
+}
+
+function rows(pairs) {
+  return pairs.filter(([, value]) => value !== undefined && value !== "")
+              .map(([name, value]) => `<tr><th>${escape(name)}</th><td>${value}</td></tr>`)
+              .join("");
+}
+
+function packetTable(packets) {
+  if (packets.length === 0) {
+    return `<p class="muted">none</p>`;
+  }
+  return packets.map(({ block, packet }) => {
+    const owners = packet.owners ? packet.owners.map((owner) => `<div class="key">${escape(owner)}</div>`).join("") : undefined;
+    return `<table>${rows([
+      ["block",        block === undefined ? undefined : block === null ? `<span class="muted">in the mempool</span>` : blockLink(block)],
+      ["hash",         `<span class="hex">${escape(packet.hash)}</span>`],
+      ["algorithm",    packet.hash_algorithm && escape(packet.hash_algorithm)],
+      ["owner",        packet.owner && `<span class="key">${escape(packet.owner)}</span>`],
+      ["owners",       owners && `${escape(packet.threshold)} of ${owners}`],
+      ["content type", packet.content_type && escape(packet.content_type)],
+      ["size",         packet.size && escape(packet.size)],
+      ["memo",         packet.memo && escape(packet.memo)],
+    ])}</table>`;
+  }).join("<hr>");
+}
+
+async function showHome() {
+  const [blocks, status] = await Promise.all([api("api/blocks"), api("api/peers")]);
+  const blockRows = blocks.map((block) => `<tr>
+      <td>${blockLink(block.index)}</td>
+      <td>${blockLink(block.hash)}</td>
+      <td>${block.packets}</td>
+      <td>${block.records}</td>
+    </tr>`).join("");
+  const peerRows = status.peers.map((peer) => `<tr>
+      <td>${peer.id}</td>
+      <td>${escape(peer.remote)}</td>
+      <td class="hex">${escape(peer.peer_id || "")}</td>
+    </tr>`).join("");
+  view.innerHTML = `
+    <section>
+      <h2>Latest blocks</h2>
+      <table>
+        <tr><th>index</th><th>hash</th><th>packets</th><th>records</th></tr>
+        ${blockRows}
+      </table>
+    </section>
+    <section>
+      <h2>Node</h2>
+      <table>${rows([
+        ["address", escape(status.address)],
+        ["id",      `<span class="hex">${escape(status.id)}</span>`],
+        ["seed",    escape(status.seed)],
+        ["height",  blockLink(status.height)],
+        ["mempool", `${status.mempool_packets} packets, ${status.mempool_records} records`],
+      ])}</table>
+      <h2>Peers</h2>
+      ${peerRows ? `<table><tr><th>id</th><th>remote</th><th>peer id</th></tr>${peerRows}</table>` : `<p class="muted">none</p>`}
+    </section>`;
+}
+
+async function showBlock(id) {
+  const block   = await api(`api/blocks/${encodeURIComponent(id)}`);
+  const records = block.records.map((record) => `<tr>
+      <td>${record.type}</td>
+      <td class="hex">${escape(record.hash)}</td>
+      <td>${escape(record.description)}</td>
+    </tr>`).join("");
+  view.innerHTML = `
+    <section>
+      <h2>Block ${block.index}</h2>
+      <table>${rows([
+        ["hash",      `<span class="hex">${escape(block.hash)}</span>`],
+        ["previous",  block.index > 0 ? blockLink(block.prev_hash) : `<span class="muted">genesis block</span>`],
+        ["nonce",     escape(block.nonce)],
+        ["signer",    block.signer && `<span class="key">${escape(block.signer)}</span>`],
+        ["signature", block.signature && `<span class="key">${escape(block.signature)}</span>`],
+      ])}</table>
+    </section>
+    <section>
+      <h2>Packets</h2>
+      ${packetTable(block.packets.map((packet) => ({ packet })))}
+    </section>
+    <section>
+      <h2>Records</h2>
+      ${records ? `<table><tr><th>type</th><th>hash</th><th>description</th></tr>${records}</table>` : `<p class="muted">none</p>`}
+    </section>`;
+}
+
+// a number or block hash opens the block, anything else searches packets by
+// document hash and then by owner
+async function showSearch(query) {
+  if (/^\d+$/.test(query)) {
+    return showBlock(query);
+  }
+  if (/^[0-9a-fA-F]{64}$/.test(query)) {
+    try {
+      return await showBlock(query);
+    } catch (err) {
+      // not a block, so a document hash
+    }
+  }
+  const field   = /^[0-9a-fA-F]+$/.test(query) ? "hash" : "owner";
+  const packets = await api(`api/packets?${field}=${encodeURIComponent(query)}`);
+  view.innerHTML = `
+    <section>
+      <h2>Packets with ${field} ${escape(query)}</h2>
+      ${packetTable(packets)}
+    </section>`;
+}
+
+async function route() {
+  const [, page, arg] = location.hash.split("/").map(decodeURIComponent);
+  try {
+    if (page === "block") {
+      await showBlock(arg);
+    } else if (page === "search") {
+      await showSearch(arg);
+    } else {
+      await showHome();
+    }
+  } catch (err) {
+    view.innerHTML = `<section><p class="error">${escape(err.message)}</p></section>`;
+  }
+}
+
+document.getElementById("search").addEventListener("submit", (event) => {
+  event.preventDefault();
+  const query = document.getElementById("query").value.trim();
+  if (query) {
+    location.hash = `#/search/${encodeURIComponent(query)}`;
+  }
+});
+
+window.addEventListener("hashchange", route);
+route();
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// serves path from n's API, decoding a JSON answer into value
+func getAPI(t *testing.T, n *Node, path string, value interface{}) int {
+	response := httptest.NewRecorder()
+	n.newAPIHandler().ServeHTTP(response, httptest.NewRequest("GET", path, nil))
+	if value != nil && response.Code == 200 {
+		if err := json.Unmarshal(response.Body.Bytes(), value); err != nil {
+			t.Error("Answer to " + path + " is not JSON: " + err.Error())
+		}
+	}
+	return response.Code
+}
+
+func TestExplorerBlocks(t *testing.T) {
+	n := newNode()
+	n.blockchain = testBlockchain(3)
+
+	var blocks []blockSummary
+	if getAPI(t, n, "/api/blocks?limit=2", &blocks) != 200 || len(blocks) != 2 || blocks[0].Index != 3 || blocks[1].Index != 2 {
+		t.Error("/api/blocks did not list the latest blocks, newest first")
+	}
+	if getAPI(t, n, "/api/blocks?limit=0", nil) != 400 {
+		t.Error("/api/blocks accepted a limit of 0")
+	}
+
+	var block blockView
+	if getAPI(t, n, "/api/blocks/2", &block) != 200 || block.Hash != hex.EncodeToString(n.blockchain.Blocks[2].Hash) {
+		t.Error("/api/blocks did not find a block by index")
+	}
+	block = blockView{}
+	if getAPI(t, n, "/api/blocks/"+hex.EncodeToString(n.blockchain.Blocks[1].Hash), &block) != 200 || block.Index != 1 {
+		t.Error("/api/blocks did not find a block by hash")
+	}
+	if block.PrevHash != hex.EncodeToString(genesisBlock.Hash) || block.Packets == nil {
+		t.Error("Block is not shown in full")
+	}
+	for _, id := range []string{"4", "nothex", hex.EncodeToString([]byte("not a block hash"))} {
+		if getAPI(t, n, "/api/blocks/"+id, nil) != 404 {
+			t.Error("/api/blocks found a block for " + id)
+		}
+	}
+}
+
+func TestExplorerCountsAnchorRecords(t *testing.T) {
+	n      := newNode()
+	keys   := GenerateNewKeypair()
+	hash   := hashDocument([]byte("anchored"))
+	packet := Packet{Hash: hash, Owner: keys.Public, Signature: signHash(hash, *keys)}
+	n.blockchain = testBlockchain(1)
+	n.blockchain.Blocks[1].Records = []Record{newRecord(anchorRecord, packet)}
+
+	var blocks []blockSummary
+	var block blockView
+	if getAPI(t, n, "/api/blocks", &blocks) != 200 || getAPI(t, n, "/api/blocks/1", &block) != 200 {
+		t.Fatal("/api/blocks did not answer")
+	}
+	if blocks[0].Packets != 1 || len(block.Packets) != blocks[0].Packets {
+		t.Errorf("Block lists %v packets, but its summary counts %v", len(block.Packets), blocks[0].Packets)
+	}
+}
+
+func TestExplorerPackets(t *testing.T) {
+	n      := newNode()
+	keys   := GenerateNewKeypair()
+	hash   := hashDocument([]byte("explorer"))
+	packet := Packet{Hash: hash, Owner: keys.Public, Signature: signHash(hash, *keys)}
+	n.blockchain = testBlockchain(1)
+	n.blockchain.Blocks[1].Data = []Packet{packet}
+	n.curPacketList = []Packet{packet}
+
+	var found []foundPacket
+	if getAPI(t, n, "/api/packets?hash="+hex.EncodeToString(hash), &found) != 200 || len(found) != 2 {
+		t.Fatal("/api/packets did not find the packet on the chain and in the mempool")
+	}
+	if found[0].Block == nil || *found[0].Block != 1 || found[1].Block != nil {
+		t.Error("Packets do not say which block holds them")
+	}
+	if found[0].Packet.Owner != string(keys.Public) || found[0].Packet.Hash != hex.EncodeToString(hash) {
+		t.Error("Packet is not shown with a base58 owner and hex hash")
+	}
+
+	found = nil
+	if getAPI(t, n, "/api/packets?owner="+string(GenerateNewKeypair().Public), &found) != 200 || len(found) != 0 {
+		t.Error("/api/packets found packets of another owner")
+	}
+	if getAPI(t, n, "/api/packets", nil) != 400 || getAPI(t, n, "/api/packets?hash=zz", nil) != 400 {
+		t.Error("/api/packets accepted a search without a valid hash or owner")
+	}
+}
+
+func TestExplorerPeersAndPage(t *testing.T) {
+	n := newNode()
+	n.address = "127.0.0.1:1999"
+
+	var status nodeStatus
+	if getAPI(t, n, "/api/peers", &status) != 200 || status.Address != n.address || status.Peers == nil {
+		t.Error("/api/peers did not describe the node")
+	}
+
+	for _, path := range []string{"/", "/app.js", "/style.css"} {
+		response := httptest.NewRecorder()
+		n.newAPIHandler().ServeHTTP(response, httptest.NewRequest("GET", path, nil))
+		if response.Code != 200 || response.Body.Len() == 0 {
+			t.Error("Explorer did not serve " + path)
+		}
+		if path == "/" && !strings.Contains(response.Body.String(), "app.js") {
+			t.Error("Explorer page does not load its script")
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/*
+format.go renders hashes, keys and blocks for people to read, on the
+command line and in the block explorer.  Hashes are shown in hex, the
+form sha256sum prints, and keys and signatures in base58, the form
+genkeys prints.  The command line prints blocks and packets in the format
+of output.format, or --format:
+
+  text  one field a line
+  json  the JSON the explorer's API answers with, for scripts
+*/
+
+// set from the output section of the config, like difficulty
+var outputFormat = "text"
+
+type packetView struct {
+	Hash          string   `json:"hash"`
+	Owner         string   `json:"owner,omitempty"`
+	Signature     string   `json:"signature,omitempty"`
+	Threshold     uint32   `json:"threshold,omitempty"`
+	Owners        []string `json:"owners,omitempty"`
+	Signatures    []string `json:"signatures,omitempty"`
+	HashAlgorithm string   `json:"hash_algorithm,omitempty"`
+	ContentType   string   `json:"content_type,omitempty"`
+	Size          uint64   `json:"size,omitempty"`
+	Memo          string   `json:"memo,omitempty"`
+}
+
+type recordView struct {
+	Type        uint8  `json:"type"`
+	Hash        string `json:"hash"`
+	Description string `json:"description"`
+}
+
+type blockView struct {
+	Index     uint32       `json:"index"`
+	Hash      string       `json:"hash"`
+	PrevHash  string       `json:"prev_hash"`
+	Nonce     uint32       `json:"nonce"`
+	Signer    string       `json:"signer,omitempty"`
+	Signature string       `json:"signature,omitempty"`
+	Packets   []packetView `json:"packets"`
+	Records   []recordView `json:"records"`
+}
+
+// everything the node command prints
+type nodeView struct {
+	nodeStatus
+	Seeds      []string    `json:"seeds"`
+	SeenBlocks []string    `json:"seen_blocks"`
+	Blocks     []blockView `json:"blocks"`
+}
+
+func formatHash(hash []byte) string {
+	return hex.EncodeToString(hash)
+}
+
+// keys and signatures are held in base58 already
+func formatKey(key []byte) string {
+	return string(key)
+}
+
+func newPacketView(packet Packet) packetView {
+	view := packetView{Hash:          formatHash(packet.Hash),
+	                   Owner:         formatKey(packet.Owner),
+	                   Signature:     formatKey(packet.Signature),
+	                   Threshold:     packet.Threshold,
+	                   HashAlgorithm: packet.HashAlgorithm,
+	                   ContentType:   packet.ContentType,
+	                   Size:          packet.Size,
+	                   Memo:          packet.Memo}
+	for i := range packet.Owners {
+		view.Owners     = append(view.Owners, formatKey(packet.Owners[i]))
+		view.Signatures = append(view.Signatures, formatKey(packet.Signatures[i]))
+	}
+	return view
+}
+
+func newBlockView(block Block) blockView {
+	view := blockView{Index:     block.Index,
+	                  Hash:      formatHash(block.Hash),
+	                  PrevHash:  formatHash(block.PrevHash),
+	                  Nonce:     block.Nonce,
+	                  Signer:    formatKey(block.Signer),
+	                  Signature: formatKey(block.Signature),
+	                  Packets:   []packetView{},
+	                  Records:   []recordView{}}
+	for _, packet := range blockPackets(block) { // the packets anchored by records too, as the explorer counts them
+		view.Packets = append(view.Packets, newPacketView(packet))
+	}
+	for _, record := range block.Records {
+		view.Records = append(view.Records, recordView{Type:        record.Type,
+		                                               Hash:        formatHash(hashRecord(record)),
+		                                               Description: describeRecord(record)})
+	}
+	return view
+}
+
+// the hashes of the blocks the node has seen, in hex and sorted so they print the same each time
+func seenBlockHashes(seenBlocks map[string]bool) []string {
+	hashes := []string{}
+	for blockHash := range seenBlocks {
+		hashes = append(hashes, formatHash([]byte(blockHash)))
+	}
+	sort.Strings(hashes)
+	return hashes
+}
+
+func formatJSON(value interface{}) string {
+	encoded, err := json.MarshalIndent(value, "", "  ")
+	if err != nil { // views only hold strings and numbers
+		panic(err)
+	}
+	return string(encoded) + "\n"
+}
+
+// adds indent to the start of every line of text
+func indentLines(text, indent string) string {
+	return indent + strings.ReplaceAll(strings.TrimSuffix(text, "\n"), "\n", "\n"+indent) + "\n"
+}
+
+func formatPacket(packet Packet) string {
+	view := newPacketView(packet)
+	if outputFormat == "json" {
+		return formatJSON(view)
+	}
+	text := &strings.Builder{}
+	fmt.Fprintf(text, "Packet %v\n", view.Hash)
+	if len(view.Owners) == 0 {
+		fmt.Fprintf(text, "  Owner:        %v\n", view.Owner)
+		fmt.Fprintf(text, "  Signature:    %v\n", view.Signature)
+	} else {
+		fmt.Fprintf(text, "  Owners:       %v of %v must sign\n", view.Threshold, len(view.Owners))
+		for i, owner := range view.Owners {
+			signature := view.Signatures[i]
+			if signature == "" {
+				signature = "(not signed)"
+			}
+			fmt.Fprintf(text, "    %v\n      %v\n", owner, signature)
+		}
+	}
+	if view.HashAlgorithm != "" {
+		fmt.Fprintf(text, "  Algorithm:    %v\n", view.HashAlgorithm)
+	}
+	if view.ContentType != "" {
+		fmt.Fprintf(text, "  Content type: %v\n", view.ContentType)
+	}
+	if view.Size != 0 {
+		fmt.Fprintf(text, "  Size:         %v bytes\n", view.Size)
+	}
+	if view.Memo != "" {
+		fmt.Fprintf(text, "  Memo:         %q\n", view.Memo)
+	}
+	return text.String()
+}
+
+func formatBlock(block Block) string {
+	view := newBlockView(block)
+	if outputFormat == "json" {
+		return formatJSON(view)
+	}
+	text := &strings.Builder{}
+	fmt.Fprintf(text, "Block #%v\n", view.Index)
+	fmt.Fprintf(text, "  Hash:      %v\n", view.Hash)
+	fmt.Fprintf(text, "  PrevHash:  %v\n", view.PrevHash)
+	fmt.Fprintf(text, "  Nonce:     %v\n", view.Nonce)
+	if view.Signer != "" {
+		fmt.Fprintf(text, "  Signer:    %v\n", view.Signer)
+		fmt.Fprintf(text, "  Signature: %v\n", view.Signature)
+	}
+	fmt.Fprintf(text, "  Packets:   %v\n", len(view.Packets))
+	for _, packet := range blockPackets(block) {
+		text.WriteString(indentLines(formatPacket(packet), "    "))
+	}
+	fmt.Fprintf(text, "  Records:   %v\n", len(view.Records))
+	for _, record := range view.Records {
+		fmt.Fprintf(text, "    %v  %v\n", record.Hash, record.Description)
+	}
+	return text.String()
+}
+
+func formatBlockchain(blockchain Blockchain) string {
+	if outputFormat == "json" {
+		views := []blockView{}
+		for _, block := range blockchain.Blocks {
+			views = append(views, newBlockView(block))
+		}
+		return formatJSON(views)
+	}
+	text := &strings.Builder{}
+	for _, block := range blockchain.Blocks {
+		text.WriteString(indentLines(formatBlock(block), "  "))
+	}
+	return text.String()
+}
+
+func (n *Node) getNodeView() nodeView {
+	view := nodeView{nodeStatus: n.getStatus()}
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	view.Seeds      = append([]string{}, n.seeds...)
+	view.SeenBlocks = seenBlockHashes(n.seenBlocks)
+	view.Blocks     = []blockView{}
+	for _, block := range n.blockchain.Blocks {
+		view.Blocks = append(view.Blocks, newBlockView(block))
+	}
+	return view
+}
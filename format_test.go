@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// sets outputFormat for the test, restoring it afterwards
+func useOutputFormat(t *testing.T, format string) {
+	previous := outputFormat
+	outputFormat = format
+	t.Cleanup(func() { outputFormat = previous })
+}
+
+func TestFormatBlockText(t *testing.T) {
+	useOutputFormat(t, "text")
+	keys  := GenerateNewKeypair()
+	hash  := hashDocument([]byte("format"))
+	block := testBlockchain(1).Blocks[1]
+	block.Data = []Packet{{Hash: hash, Owner: keys.Public, Signature: signHash(hash, *keys), Memo: "minutes"}}
+
+	text := formatBlock(block)
+	for _, want := range []string{"Block #1\n", hex.EncodeToString(block.Hash), hex.EncodeToString(block.PrevHash), hex.EncodeToString(hash), string(keys.Public), `"minutes"`} {
+		if !strings.Contains(text, want) {
+			t.Error("Block text is missing " + want + ":\n" + text)
+		}
+	}
+	if strings.Contains(text, "[") {
+		t.Error("Block text holds a byte array:\n" + text)
+	}
+	if !strings.HasPrefix(formatBlockchain(testBlockchain(1)), "  Block #0\n") {
+		t.Error("Blockchain text does not list its blocks from the genesis block")
+	}
+}
+
+func TestFormatBlockJSON(t *testing.T) {
+	useOutputFormat(t, "json")
+	block := testBlockchain(1).Blocks[1]
+
+	var view blockView
+	if err := json.Unmarshal([]byte(formatBlock(block)), &view); err != nil {
+		t.Fatal("Block is not printed as JSON:", err)
+	}
+	if view.Index != 1 || view.Hash != hex.EncodeToString(block.Hash) || view.PrevHash != hex.EncodeToString(genesisBlock.Hash) {
+		t.Error("Block JSON does not match the block")
+	}
+
+	var views []blockView
+	if err := json.Unmarshal([]byte(formatBlockchain(testBlockchain(2))), &views); err != nil || len(views) != 3 {
+		t.Error("Blockchain is not printed as a JSON list of its blocks")
+	}
+
+	var packet packetView
+	multiSig := Packet{Hash: []byte{0xab}, Threshold: 1, Owners: [][]byte{[]byte("owner")}, Signatures: [][]byte{[]byte("signature")}}
+	if err := json.Unmarshal([]byte(formatPacket(multiSig)), &packet); err != nil || packet.Hash != "ab" || packet.Owners[0] != "owner" || packet.Owner != "" {
+		t.Error("Packet is not printed as JSON")
+	}
+}
+
+func TestSeenBlockHashes(t *testing.T) {
+	hashes := seenBlockHashes(map[string]bool{"\x02": true, "\x01": true})
+	if len(hashes) != 2 || hashes[0] != "01" || hashes[1] != "02" {
+		t.Error("Seen blocks are not listed as sorted hex:", hashes)
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+/*
+hdkeys.go derives any number of keypairs from a single mnemonic seed.
+Mnemonics follow BIP-39, so the seed phrase can be written down and
+restored later.  Child keys are derived by path (eg. m/0'/3) following
+BIP-32, adapted to the P224 curve used by the rest of the node.
+*/
+
+const hardenedKeyStart = 0x80000000 // indexes at or above this are hardened
+
+var masterKeySalt = []byte("go-blockchain P224 seed")
+
+type extendedKey struct {
+	key       *big.Int // private scalar
+	chainCode []byte
+	depth     uint8
+	index     uint32
+}
+
+// creates a new random mnemonic from the given number of bits of entropy
+func newMnemonic(entropyBits int) (string, error) {
+	if entropyBits%32 != 0 || entropyBits < 128 || entropyBits > 256 {
+		return "", errors.New("entropy must be a multiple of 32 bits between 128 and 256")
+	}
+	entropy := make([]byte, entropyBits/8)
+	if _, err := rand.Read(entropy); err != nil {
+		return "", err
+	}
+	return entropyToMnemonic(entropy)
+}
+
+func entropyToMnemonic(entropy []byte) (string, error) {
+	entropyBits := len(entropy) * 8
+	if entropyBits%32 != 0 || entropyBits < 128 || entropyBits > 256 {
+		return "", errors.New("entropy must be a multiple of 32 bits between 128 and 256")
+	}
+	checksumBits := entropyBits / 32
+	checksum     := sha256.Sum256(entropy)
+
+	// entropy followed by the checksum bits, read off 11 bits at a time
+	bits := new(big.Int).SetBytes(entropy)
+	bits.Lsh(bits, uint(checksumBits))
+	bits.Or(bits, big.NewInt(int64(checksum[0]>>(8-uint(checksumBits)))))
+
+	wordCount := (entropyBits + checksumBits) / 11
+	words     := make([]string, wordCount)
+	mask      := big.NewInt(2047)
+	for i := wordCount - 1; i >= 0; i-- {
+		index   := new(big.Int).And(bits, mask).Int64()
+		words[i] = mnemonicWordlist[index]
+		bits.Rsh(bits, 11)
+	}
+	return strings.Join(words, " "), nil
+}
+
+// checks the words and checksum of a mnemonic and returns its entropy
+func mnemonicToEntropy(mnemonic string) ([]byte, error) {
+	words := strings.Fields(strings.ToLower(mnemonic))
+	if len(words)%3 != 0 || len(words) < 12 || len(words) > 24 {
+		return nil, fmt.Errorf("mnemonic must have 12, 15, 18, 21 or 24 words, got %d", len(words))
+	}
+
+	bits := new(big.Int)
+	for _, word := range words {
+		index := mnemonicWordIndex(word)
+		if index < 0 {
+			return nil, fmt.Errorf("%q is not in the wordlist", word)
+		}
+		bits.Lsh(bits, 11)
+		bits.Or(bits, big.NewInt(int64(index)))
+	}
+
+	checksumBits := len(words) * 11 / 33
+	entropyBits  := len(words)*11 - checksumBits
+	checksum     := new(big.Int).And(bits, big.NewInt(int64(1<<uint(checksumBits)-1))).Int64()
+	bits.Rsh(bits, uint(checksumBits))
+
+	entropy := make([]byte, entropyBits/8)
+	bits.FillBytes(entropy)
+
+	expected := sha256.Sum256(entropy)
+	if int64(expected[0]>>(8-uint(checksumBits))) != checksum {
+		return nil, errors.New("mnemonic checksum does not match, check the words and their order")
+	}
+	return entropy, nil
+}
+
+// words may be given in full or abbreviated to their first four letters
+func mnemonicWordIndex(word string) int {
+	for i, w := range mnemonicWordlist {
+		if w == word || (len(word) == 4 && strings.HasPrefix(w, word)) {
+			return i
+		}
+	}
+	return -1
+}
+
+func mnemonicToSeed(mnemonic, passphrase string) []byte {
+	normalized := strings.Join(strings.Fields(strings.ToLower(mnemonic)), " ")
+	seed, _    := pbkdf2.Key(sha512.New, normalized, []byte("mnemonic"+passphrase), 2048, 64)
+	return seed
+}
+
+func newMasterKey(seed []byte) (*extendedKey, error) {
+	mac := hmac.New(sha512.New, masterKeySalt)
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	key := new(big.Int).SetBytes(sum[:28])
+	if key.Sign() == 0 || key.Cmp(elliptic.P224().Params().N) >= 0 {
+		return nil, errors.New("seed produces an invalid master key, use another seed")
+	}
+	return &extendedKey{key: key, chainCode: sum[32:]}, nil
+}
+
+func (k *extendedKey) deriveChild(index uint32) (*extendedKey, error) {
+	curve := elliptic.P224()
+	data  := []byte{}
+	if index >= hardenedKeyStart {
+		// hardened children commit to the private key, so leaking a child
+		// and the parent's public data does not reveal the parent
+		data = append(data, 0)
+		data = append(data, k.key.FillBytes(make([]byte, 28))...)
+	} else {
+		x, y := curve.ScalarBaseMult(k.key.FillBytes(make([]byte, 28)))
+		data  = append(data, elliptic.MarshalCompressed(curve, x, y)...)
+	}
+	indexBytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(indexBytes, index)
+	data = append(data, indexBytes...)
+
+	mac := hmac.New(sha512.New, k.chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	tweak := new(big.Int).SetBytes(sum[:28])
+	if tweak.Cmp(curve.Params().N) >= 0 {
+		return nil, fmt.Errorf("index %d produces an invalid key, use the next index", index)
+	}
+	childKey := new(big.Int).Add(tweak, k.key)
+	childKey.Mod(childKey, curve.Params().N)
+	if childKey.Sign() == 0 {
+		return nil, fmt.Errorf("index %d produces an invalid key, use the next index", index)
+	}
+
+	return &extendedKey{key:       childKey,
+	                    chainCode: sum[32:],
+	                    depth:     k.depth + 1,
+	                    index:     index}, nil
+}
+
+func (k *extendedKey) derivePath(path string) (*extendedKey, error) {
+	indexes, err := parseDerivationPath(path)
+	if err != nil {
+		return nil, err
+	}
+	key := k
+	for _, index := range indexes {
+		key, err = key.deriveChild(index)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// parses paths like m/0'/1/2, where ' (or h) marks a hardened index
+func parseDerivationPath(path string) ([]uint32, error) {
+	parts := strings.Split(strings.TrimSpace(path), "/")
+	if parts[0] != "m" {
+		return nil, fmt.Errorf("derivation path %q must start with m", path)
+	}
+
+	indexes := []uint32{}
+	for _, part := range parts[1:] {
+		var offset uint32
+		if strings.HasSuffix(part, "'") || strings.HasSuffix(part, "h") {
+			offset = hardenedKeyStart
+			part   = part[:len(part)-1]
+		}
+		index, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || index >= hardenedKeyStart {
+			return nil, fmt.Errorf("invalid index %q in derivation path", part)
+		}
+		indexes = append(indexes, uint32(index)+offset)
+	}
+	return indexes, nil
+}
+
+func (k *extendedKey) keypair() Keypair {
+	return keypairFromPrivateKey(k.key)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestEntropyToMnemonic(t *testing.T){
+	// vectors from the BIP-39 specification
+	vectors := map[string]string{
+		"00000000000000000000000000000000": "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+		"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f": "legal winner thank year wave sausage worth useful legal winner thank yellow",
+		"80808080808080808080808080808080": "letter advice cage absurd amount doctor acoustic avoid letter advice cage above",
+		"ffffffffffffffffffffffffffffffff": "zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong",
+		"9e885d952ad362caeb4efe34a8e91bd2": "ozone drill grab fiber curtain grace pudding thank cruise elder eight picnic",
+	}
+	for entropyHex, expected := range vectors {
+		entropy, _ := hex.DecodeString(entropyHex)
+		mnemonic, err := entropyToMnemonic(entropy)
+		if err != nil || mnemonic != expected {
+			t.Errorf("entropy %v gave mnemonic %q", entropyHex, mnemonic)
+		}
+
+		restored, err := mnemonicToEntropy(mnemonic)
+		if err != nil || hex.EncodeToString(restored) != entropyHex {
+			t.Errorf("mnemonic %q does not restore its entropy", mnemonic)
+		}
+	}
+}
+
+func TestMnemonicToEntropyRejectsInvalid(t *testing.T){
+	// last word changes the checksum
+	if _, err := mnemonicToEntropy("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon"); err == nil {
+		t.Error("accepts mnemonic with a bad checksum")
+	}
+	if _, err := mnemonicToEntropy("abandon abandon abandon"); err == nil {
+		t.Error("accepts mnemonic with too few words")
+	}
+	if _, err := mnemonicToEntropy("notaword abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"); err == nil {
+		t.Error("accepts mnemonic with a word outside the wordlist")
+	}
+
+	// words may be abbreviated to their first four letters
+	if _, err := mnemonicToEntropy("aban aban aban aban aban aban aban aban aban aban aban abou"); err != nil {
+		t.Error("fails to accept abbreviated words")
+	}
+}
+
+func TestMnemonicToSeed(t *testing.T){
+	seed := mnemonicToSeed("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", "TREZOR")
+	expected := "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04"
+	if hex.EncodeToString(seed) != expected {
+		t.Error("seed does not match BIP-39 test vector")
+	}
+}
+
+func TestDerivePath(t *testing.T){
+	mnemonic, err := newMnemonic(128)
+	if err != nil {
+		t.Fatal(err)
+	}
+	master, err := newMasterKey(mnemonicToSeed(mnemonic, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key01, _ := master.derivePath("m/0'/1")
+	key02, _ := master.derivePath("m/0'/1")
+	key03, _ := master.derivePath("m/0'/2")
+	key04, _ := master.derivePath("m/0/1")
+
+	keys01 := key01.keypair()
+	if string(keys01.Private) != string(key02.keypair().Private) {
+		t.Error("same path derives different keys")
+	}
+	if string(keys01.Private) == string(key03.keypair().Private) {
+		t.Error("sibling paths derive the same key")
+	}
+	if string(keys01.Private) == string(key04.keypair().Private) {
+		t.Error("hardened and normal paths derive the same key")
+	}
+
+	// derived keys must sign like generated keys
+	hash := hashDocument([]byte("derived"))
+	signature := signHash(hash, keys01)
+	if !SignatureVerify(keys01.Public, signature, hash) {
+		t.Error("derived keypair fails to produce a valid signature")
+	}
+
+	// a different passphrase gives an unrelated tree
+	other, _ := newMasterKey(mnemonicToSeed(mnemonic, "passphrase"))
+	key05, _ := other.derivePath("m/0'/1")
+	if string(keys01.Private) == string(key05.keypair().Private) {
+		t.Error("passphrase does not change the derived keys")
+	}
+}
+
+func TestParseDerivationPath(t *testing.T){
+	indexes, err := parseDerivationPath("m/44'/0h/7")
+	if err != nil || len(indexes) != 3 {
+		t.Fatal("fails to parse a valid path")
+	}
+	if indexes[0] != hardenedKeyStart+44 || indexes[1] != hardenedKeyStart || indexes[2] != 7 {
+		t.Errorf("parsed wrong indexes %v", indexes)
+	}
+
+	for _, path := range []string{"0/1", "m/x", "m/2147483648", "m//1"} {
+		if _, err := parseDerivationPath(path); err == nil {
+			t.Errorf("accepts invalid path %q", path)
+		}
+	}
+}
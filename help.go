@@ -17,17 +17,47 @@ COMMANDS:
 
 GLOBAL OPTIONS:
     -l, --listen     assigns the listening port for the server        (default = 1999).
-    -s, --seed       assigns the port of the seed                     (default = 2000).
-    -p, --public     launch node using a your public IP               (default = false).
+    -s, --seed       adds the port of a seed, repeat for more seeds   (default = none).
+    --seeds-file     reads more seeds from a file, one or more a line (default = none).
+    -p, --public     advertise the address your peers see you at      (default = false).
+    --external-addr  advertise this host:port instead                 (default = none).
+    -d, --datadir    directory to save the blockchain and peers in    (default = none).
+    -c, --config     reads settings from a TOML or YAML config file   (default = none).
+    --log-file       writes the node's logs to a file                 (default = stderr).
+    --api-addr       serves the block explorer and API on host:port   (default = none).
+    --format         prints blocks and packets as text or json        (default = text).
+    -t, --tui        shows the node in a full screen console          (default = false).
+    --dev            seals blocks as documents arrive, on a new chain (default = false).
     -h, --help       prints help information
 
 NODE COMMANDS:
     getconns  requests the list of nodes from your seed node and attempts to connect to each
     getchain  requests seed node for their version of the blockchain
     genkeys   generates and prints a public and private keypair
+    newseed   creates a new seed phrase from which keypairs can be derived
+    restore   restores a seed phrase so its keypairs can be derived again
+    derive    derives the keypair at a path (eg. m/0'/1) from the current seed
     node      prints the data associated with your node
+    block     prints one block, by its index or its hash in hex, eg. 'block 3'
     upload    initates the process of uploading a signed document hash to the blockchain
+    cosign    creates your partial signature for a document owned jointly with other keys
+    multiupload uploads a jointly owned document once enough co-owners have signed it
     lookup    initates the process of verifying a document hash and public keypair is on the blockchain
+    verifyfile checks that a file is part of a directory uploaded to the blockchain
+    batch     uploads many documents listed in a file as a single packet
+    verifyproof checks an inclusion proof saved by lookup against your blockchain
+    rotate    retires one of your keys in favour of a successor key
+    revoke    retires one of your keys, eg. after it has leaked
+    claim     records on the blockchain a name for the owner of one of your keys
+    annotate  records on the blockchain a short signed note, optionally about a document hash
+    authority adds or removes a proof-of-authority sealer, signed by most of the current ones
+    seal      seals a block holding whatever is waiting to be mined, under --dev
+    export-chain writes your blockchain to a file another node can import
+    import-chain checks and adds the blocks of an exported file, carrying on where it stopped
+    checkchain checks every block of your blockchain, and the saved one, and names the first bad block
+    reindex   rebuilds the seen blocks and mempool from your blockchain
+    config    prints the configuration your node is running with, enter 'config dump'
+    quit      saves your node, says goodbye to its peers and exits
     help      prints the node command help information`)
 }
 
@@ -38,8 +68,29 @@ NODE COMMANDS:
     getconns  requests the list of nodes from your seed node and attempts to connect to each
     getchain  requests seed node for their version of the blockchain
     genkeys   generates and prints a public and private keypair
+    newseed   creates a new seed phrase from which keypairs can be derived
+    restore   restores a seed phrase so its keypairs can be derived again
+    derive    derives the keypair at a path (eg. m/0'/1) from the current seed
     node      prints the data associated with your node
+    block     prints one block, by its index or its hash in hex, eg. 'block 3'
     upload    initates the process of uploading a signed document hash to the blockchain
+    cosign    creates your partial signature for a document owned jointly with other keys
+    multiupload uploads a jointly owned document once enough co-owners have signed it
     lookup    initates the process of verifying a document hash and public keypair is on the blockchain
+    verifyfile checks that a file is part of a directory uploaded to the blockchain
+    batch     uploads many documents listed in a file as a single packet
+    verifyproof checks an inclusion proof saved by lookup against your blockchain
+    rotate    retires one of your keys in favour of a successor key
+    revoke    retires one of your keys, eg. after it has leaked
+    claim     records on the blockchain a name for the owner of one of your keys
+    annotate  records on the blockchain a short signed note, optionally about a document hash
+    authority adds or removes a proof-of-authority sealer, signed by most of the current ones
+    seal      seals a block holding whatever is waiting to be mined, under --dev
+    export-chain writes your blockchain to a file another node can import
+    import-chain checks and adds the blocks of an exported file, carrying on where it stopped
+    checkchain checks every block of your blockchain, and the saved one, and names the first bad block
+    reindex   rebuilds the seen blocks and mempool from your blockchain
+    config    prints the configuration your node is running with, enter 'config dump'
+    quit      saves your node, says goodbye to its peers and exits
     help      prints the node command help information`)
 }
\ No newline at end of file
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+/*
+identity.go gives each node a long-term identity key, an ed25519 key kept
+in the data directory, or at network.identity_key.  The tls transport
+presents a certificate for the key to every peer, and the TLS 1.3
+handshake proves the peer holds the key of the certificate it presents,
+so a peer is known by its key rather than the address it connects from.
+
+A peer's ID is the SHA-256 of its public key, in hex.  Setting
+network.allowed_peers to a list of IDs makes the node turn away every
+other peer, for a network of only known nodes.
+*/
+
+const identityFile = "identity.key"
+
+// where the identity key is kept, empty when the node keeps nothing between runs
+func identityPath(config Config) string {
+	if config.Network.IdentityKey != "" {
+		return config.Network.IdentityKey
+	}
+	if config.Storage.DataDir != "" {
+		return filepath.Join(config.Storage.DataDir, identityFile)
+	}
+	return ""
+}
+
+// the identity key the tls transport authenticates with, or nil when the
+// node needs none: under other transports, or when tls_cert replaces it
+func loadTransportIdentity(config Config) (ed25519.PrivateKey, error) {
+	if config.Network.Transport != "tls" || config.Network.TLSCert != "" {
+		return nil, nil
+	}
+	return loadIdentity(identityPath(config))
+}
+
+// reads the identity key at path, creating it the first time; without a
+// path the node gets a new identity every run
+func loadIdentity(path string) (ed25519.PrivateKey, error) {
+	if path == "" {
+		_, identity, err := ed25519.GenerateKey(rand.Reader)
+		return identity, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		_, identity, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(identity)
+		if err != nil {
+			return nil, err
+		}
+		err = writeFileAtomic(path, func(file *os.File) error { // created readable only by its owner
+			return pem.Encode(file, &pem.Block{Type: "PRIVATE KEY", Bytes: der})
+		})
+		return identity, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, errors.New(path + " is not a PEM private key")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	identity, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New(path + " is not an ed25519 key")
+	}
+	return identity, nil
+}
+
+// a self signed certificate for the identity key, for the tls transport to present
+func identityCertificate(identity ed25519.PrivateKey) (tls.Certificate, error) {
+	template := &x509.Certificate{SerialNumber: big.NewInt(1),
+	                              Subject:      pkix.Name{CommonName: peerIDOfKey(identity.Public())},
+	                              NotBefore:    time.Now().Add(-time.Hour),
+	                              NotAfter:     time.Now().AddDate(100, 0, 0), // the key is the identity, not the certificate
+	                              KeyUsage:     x509.KeyUsageDigitalSignature,
+	                              ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, identity.Public(), identity)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: identity}, nil
+}
+
+// the ID of the holder of a public key
+func peerIDOfKey(publicKey crypto.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+// the ID a certificate's holder is known by
+func peerIDOfCertificate(der []byte) string {
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return ""
+	}
+	return peerIDOfKey(cert.PublicKey)
+}
+
+// the ID of the peer on an authenticated connection, empty for other connections
+func peerID(conn net.Conn) string {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return ""
+	}
+	certs := tlsConn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return ""
+	}
+	return peerIDOfKey(certs[0].PublicKey)
+}
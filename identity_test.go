@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadIdentity(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", identityFile)
+	identity, err := loadIdentity(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Error("Identity key was not saved readable only by its owner")
+	}
+
+	reloaded, err := loadIdentity(path)
+	if err != nil || !identity.Equal(reloaded) {
+		t.Error("Identity changed between runs")
+	}
+
+	ephemeral, _ := loadIdentity("")
+	if ephemeral == nil || ephemeral.Equal(identity) {
+		t.Error("Node without a data directory did not get a new identity")
+	}
+
+	os.WriteFile(path, []byte("not a key"), 0600)
+	if _, err := loadIdentity(path); err == nil {
+		t.Error("Loaded an identity from a file that is not a key")
+	}
+}
+
+func TestIdentityPath(t *testing.T) {
+	config := defaultConfig()
+	if identityPath(config) != "" {
+		t.Error("Node that keeps nothing has an identity path")
+	}
+	config.Storage.DataDir = "data"
+	if identityPath(config) != filepath.Join("data", identityFile) {
+		t.Error("Identity is not kept in the data directory")
+	}
+	config.Network.IdentityKey = "node.key"
+	if identityPath(config) != "node.key" {
+		t.Error("network.identity_key was not used")
+	}
+}
+
+func TestTransportIdentity(t *testing.T) {
+	path   := filepath.Join(t.TempDir(), "identity.key")
+	config := defaultConfig()
+	config.Network.IdentityKey = path
+	if identity, err := loadTransportIdentity(config); err != nil || identity != nil {
+		t.Error("Node on the tcp transport loaded an identity key")
+	}
+	config.Network.Transport = "tls"
+	config.Network.TLSCert   = "node.pem"
+	if identity, err := loadTransportIdentity(config); err != nil || identity != nil {
+		t.Error("Node with a tls_cert loaded an identity key")
+	}
+	if _, err := os.Stat(path); err == nil {
+		t.Error("Identity key was created for a node that does not use it")
+	}
+	config.Network.TLSCert = ""
+	if identity, err := loadTransportIdentity(config); err != nil || identity == nil {
+		t.Error("Node on the tls transport did not load an identity key:", err)
+	}
+}
+
+func TestIdentityCertificate(t *testing.T) {
+	identity, _ := loadIdentity("")
+	certificate, err := identityCertificate(identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if peerIDOfCertificate(certificate.Certificate[0]) != peerIDOfKey(identity.Public()) {
+		t.Error("Certificate is not for the identity key")
+	}
+	if len(peerIDOfKey(identity.Public())) != 64 {
+		t.Error("Peer ID is not a hex SHA-256")
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+)
+
+/*
+keyrecord.go lets an owner retire a key, for example after it has leaked.
+A key record is signed by the key it retires and is stored on chain as a
+typed record (see record.go).  A rotation names the successor key the
+owner moved to, a revocation simply retires the key.  Packets signed by a key
+stay on chain, but lookup reports whether they were anchored before or
+after the key was retired.
+*/
+
+const (
+	keyRotation   uint8 = 1
+	keyRevocation uint8 = 2
+)
+
+type KeyRecord struct {
+	Kind      uint8
+	Key       []byte // the retired key, which signs the record
+	Successor []byte // only set for rotations
+	Height    uint32 // retire the key after this height, or 0 for the block holding the record
+	Signature []byte
+}
+
+func keyRecordDigest(record KeyRecord) []byte {
+	h := sha256.New()
+
+	heightBytes := make([]byte, 4)
+	binary.LittleEndian.PutUint32(heightBytes, record.Height)
+
+	h.Write([]byte{record.Kind})
+	h.Write(lengthPrefixed(record.Key))
+	h.Write(lengthPrefixed(record.Successor))
+	h.Write(heightBytes)
+
+	return h.Sum(nil)
+}
+
+func createKeyRecord(kind uint8, keys Keypair, successor []byte, height uint32) KeyRecord {
+	record := KeyRecord{Kind:      kind,
+	                    Key:       keys.Public,
+	                    Successor: successor,
+	                    Height:    height}
+	record.Signature = signHash(keyRecordDigest(record), keys)
+	return record
+}
+
+func verifyKeyRecord(record KeyRecord) bool {
+	switch record.Kind {
+	case keyRotation:
+		if len(record.Successor) == 0 || string(record.Successor) == string(record.Key) {
+			return false
+		}
+	case keyRevocation:
+		if len(record.Successor) != 0 {
+			return false
+		}
+	default:
+		return false
+	}
+	return SignatureVerify(record.Key, record.Signature, keyRecordDigest(record))
+}
+
+func init() {
+	registerRecordType(keyRecordType, recordType{
+		name: "key record",
+		validate: func(record Record, block *Block) bool {
+			var keyRecord KeyRecord
+			if decodeRecord(record, &keyRecord) != nil || !verifyKeyRecord(keyRecord) {
+				return false
+			}
+			// a record can not put the retirement off past the block holding it, or
+			// whoever holds a leaked key could keep the key in use for good
+			return block == nil || keyRecord.Height <= block.Index
+		},
+		hash: func(record Record) []byte {
+			var keyRecord KeyRecord
+			decodeRecord(record, &keyRecord)
+			h := sha256.New()
+			h.Write(keyRecordDigest(keyRecord))
+			h.Write(lengthPrefixed(keyRecord.Signature))
+			return h.Sum(nil)
+		},
+		describe: func(record Record) string {
+			var keyRecord KeyRecord
+			decodeRecord(record, &keyRecord)
+			if keyRecord.Kind == keyRotation {
+				return fmt.Sprintf("rotation of %v to %v", string(keyRecord.Key), string(keyRecord.Successor))
+			}
+			return fmt.Sprintf("revocation of %v", string(keyRecord.Key))
+		},
+	})
+}
+
+// returns the record retiring the key earliest and the height after which it is retired.
+// another record for the key can only bring the retirement forward, so whoever holds a
+// leaked key can not undo or put off a retirement that is already on chain
+func (blockchain Blockchain) findKeyRecord(publicKey []byte) (KeyRecord, uint32, bool) {
+	earliest     := KeyRecord{}
+	retiredAfter := uint32(0)
+	found        := false
+	for _, block := range blockchain.Blocks {
+		for _, blockRecord := range block.Records {
+			var record KeyRecord
+			if blockRecord.Type != keyRecordType || decodeRecord(blockRecord, &record) != nil {
+				continue
+			}
+			if string(record.Key) != string(publicKey) {
+				continue
+			}
+			height := record.Height
+			if height == 0 {
+				height = block.Index - 1 // packets anchored in the same block as the record count as after it
+			}
+			if !found || height < retiredAfter {
+				earliest, retiredAfter, found = record, height, true
+			}
+		}
+	}
+	return earliest, retiredAfter, found
+}
+
+// returns the index of the block holding the packet with the given hash and owner
+func (blockchain Blockchain) findPacketBlockIndex(packetHash, publicKey []byte) (uint32, bool) {
+	for _, block := range blockchain.Blocks {
+		if packetListHasPacketHashAndPublicKey(blockPackets(block), packetHash, publicKey) {
+			return block.Index, true
+		}
+	}
+	return 0, false
+}
+
+// describes whether a packet anchored at packetHeight was signed while the key was still in use
+func (blockchain Blockchain) describeKeyStatus(publicKey []byte, packetHeight uint32) string {
+	record, retiredAfter, found := blockchain.findKeyRecord(publicKey)
+	if !found {
+		return fmt.Sprintf("The key has not been retired; the packet was anchored in block #%v.", packetHeight)
+	}
+
+	retirement := "revoked"
+	if record.Kind == keyRotation {
+		retirement = fmt.Sprintf("rotated to %v", string(record.Successor))
+	}
+	if packetHeight <= retiredAfter {
+		return fmt.Sprintf("The packet was anchored in block #%v, BEFORE the key was %v after block #%v.", packetHeight, retirement, retiredAfter)
+	}
+	return fmt.Sprintf("WARNING: the packet was anchored in block #%v, AFTER the key was %v after block #%v.", packetHeight, retirement, retiredAfter)
+}
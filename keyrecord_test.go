@@ -0,0 +1,121 @@
+package main
+
+import(
+	"strings"
+	"testing"
+)
+
+func TestVerifyKeyRecord(t *testing.T){
+	keys01 := GenerateNewKeypair()
+	keys02 := GenerateNewKeypair()
+
+	if !verifyKeyRecord(createKeyRecord(keyRevocation, *keys01, []byte{}, 0)) {
+		t.Error("fails to approve valid revocation")
+	}
+	if !verifyKeyRecord(createKeyRecord(keyRotation, *keys01, keys02.Public, 10)) {
+		t.Error("fails to approve valid rotation")
+	}
+
+	// rotation needs a different successor key, revocation must not have one
+	if verifyKeyRecord(createKeyRecord(keyRotation, *keys01, []byte{}, 0)) {
+		t.Error("approves rotation without successor")
+	}
+	if verifyKeyRecord(createKeyRecord(keyRotation, *keys01, keys01.Public, 0)) {
+		t.Error("approves rotation to the same key")
+	}
+	if verifyKeyRecord(createKeyRecord(keyRevocation, *keys01, keys02.Public, 0)) {
+		t.Error("approves revocation with successor")
+	}
+
+	// record must be signed by the retired key
+	record := createKeyRecord(keyRevocation, *keys01, []byte{}, 0)
+	record.Key = keys02.Public
+	if verifyKeyRecord(record) {
+		t.Error("approves record retiring someone else's key")
+	}
+
+	// height is covered by the signature
+	record = createKeyRecord(keyRevocation, *keys01, []byte{}, 5)
+	record.Height = 50
+	if verifyKeyRecord(record) {
+		t.Error("approves record whose height was changed after signing")
+	}
+}
+
+func TestDescribeKeyStatus(t *testing.T){
+	useDifficulty(t, 4294967295) // all hashes pass
+
+	keys01 := GenerateNewKeypair()
+	keys02 := GenerateNewKeypair()
+	packet := createPacket("document.txt", *keys01)
+
+	g  := &genesisBlock
+	b1 := &Block{Index: g.Index + 1, PrevHash: g.Hash, Data: []Packet{packet}}
+	b1.Hash = b1.calcHashForBlock(0)
+
+	// the key is rotated in block 2, so the same packet in block 3 was anchored afterwards
+	b2 := &Block{Index: b1.Index + 1, PrevHash: b1.Hash, Data: []Packet{},
+				 Records: []Record{newRecord(keyRecordType, createKeyRecord(keyRotation, *keys01, keys02.Public, 0))}}
+	b2.Hash = b2.calcHashForBlock(0)
+	if !b1.isValidNextBlock(b2) {
+		t.Error("fails to validate block with a key record")
+	}
+
+	b3 := &Block{Index: b2.Index + 1, PrevHash: b2.Hash, Data: []Packet{packet}}
+	b3.Hash = b3.calcHashForBlock(0)
+
+	chain := Blockchain{Blocks: []Block{*g, *b1, *b2, *b3}}
+	if !chain.isValidChain() {
+		t.Error("fails to validate chain with a key record")
+	}
+
+	before := chain.describeKeyStatus(keys01.Public, 1)
+	if !strings.Contains(before, "BEFORE") || !strings.Contains(before, string(keys02.Public)) {
+		t.Errorf("wrong status for packet anchored before rotation: %v", before)
+	}
+	after := chain.describeKeyStatus(keys01.Public, 3)
+	if !strings.Contains(after, "AFTER") {
+		t.Errorf("wrong status for packet anchored after rotation: %v", after)
+	}
+	if strings.Contains(chain.describeKeyStatus(keys02.Public, 3), "retired after") {
+		t.Error("reports successor key as retired")
+	}
+
+	// a record can not retire the key after a height past the block holding it
+	b4 := &Block{Index: b3.Index + 1, PrevHash: b3.Hash, Data: []Packet{},
+				 Records: []Record{newRecord(keyRecordType, createKeyRecord(keyRevocation, *keys01, []byte{}, 100))}}
+	b4.Hash = b4.calcHashForBlock(0)
+	if b3.isValidNextBlock(b4) {
+		t.Error("validates a key record retiring the key after a height not reached yet")
+	}
+
+	// a later record can bring the retirement forward, but not put it off
+	b4.Records = []Record{newRecord(keyRecordType, createKeyRecord(keyRevocation, *keys01, []byte{}, 4))}
+	b4.Hash    = b4.calcHashForBlock(0)
+	if !b3.isValidNextBlock(b4) {
+		t.Error("fails to validate a key record retiring the key after the block holding it")
+	}
+	chain.Blocks = append(chain.Blocks, *b4)
+	if _, height, _ := chain.findKeyRecord(keys01.Public); height != 1 {
+		t.Errorf("later record puts off the retirement, retired after %v", height)
+	}
+
+	// whichever record retires the key earliest counts, wherever it is on chain
+	first   := createKeyRecord(keyRevocation, *keys01, []byte{}, 0)
+	early   := createKeyRecord(keyRotation, *keys01, keys02.Public, 1)
+	records := Blockchain{Blocks: []Block{*g, {Index: 3, Records: []Record{newRecord(keyRecordType, first)}},
+	                                          {Index: 5, Records: []Record{newRecord(keyRecordType, early)}}}}
+	if record, height, _ := records.findKeyRecord(keys01.Public); height != 1 || record.Kind != keyRotation {
+		t.Errorf("record retiring the key earlier is not used, retired after %v", height)
+	}
+
+	// a forged record invalidates the block
+	forged := createKeyRecord(keyRevocation, *keys02, []byte{}, 0)
+	forged.Key = keys01.Public
+	b5 := *b2
+	b5.Records = []Record{newRecord(keyRecordType, forged)}
+	b5.Hash = b5.calcHashForBlock(0)
+	if b1.isValidNextBlock(&b5) {
+		t.Error("validates block with a forged key record")
+	}
+}
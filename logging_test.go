@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoggerFormats(t *testing.T) {
+	defer setLogger(newLogger(os.Stderr, defaultConfig().Logging))
+
+	out := &bytes.Buffer{}
+	setLogger(newLogger(out, LoggingConfig{Level: "info", Format: "json"}))
+	chainLog.Info("added block", "index", 1)
+	minerLog.Debug("tried nonce") // below the level
+
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatal("JSON logs should hold one entry per line:", err)
+	}
+	if entry["level"] != "INFO" || entry["subsystem"] != "chain" || entry["msg"] != "added block" || entry["index"] != 1.0 {
+		t.Error("JSON log entry is missing its level, subsystem or fields", entry)
+	}
+
+	out.Reset()
+	setLogger(newLogger(out, LoggingConfig{Level: "debug", Format: "logfmt"}))
+	p2pLog.Debug("connected", "remote", "10.0.0.1:1999")
+	if !strings.Contains(out.String(), `level=DEBUG msg=connected subsystem=p2p remote=10.0.0.1:1999`) {
+		t.Error("logfmt log entry is missing its level, subsystem or fields:", out.String())
+	}
+}
+
+func TestSetupLoggingToFile(t *testing.T) {
+	defer setLogger(newLogger(os.Stderr, defaultConfig().Logging))
+
+	logPath := filepath.Join(t.TempDir(), "node.log")
+	file, err := setupLogging(LoggingConfig{Level: "info", Format: "logfmt", File: logPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mempoolLog.Info("received packet")
+	file.Close()
+
+	logs, _ := os.ReadFile(logPath)
+	if !strings.Contains(string(logs), "subsystem=mempool") {
+		t.Error("Logs were not written to the log file")
+	}
+}
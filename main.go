@@ -1,7 +1,13 @@
 package main
 
 import(
+    "context"
     "flag"
+    "fmt"
+    "os"
+    "os/signal"
+    "strings"
+    "syscall"
 )
 
 /*------------------------*
@@ -13,9 +19,12 @@ func main() {
     flag.StringVar(&listenPort, "l", "", "")
     flag.StringVar(&listenPort, "listen", "", "")
 
-    var seedData string
-    flag.StringVar(&seedData, "s", "", "")
-    flag.StringVar(&seedData, "seed", "", "")
+    var seeds seedFlag
+    flag.Var(&seeds, "s", "")
+    flag.Var(&seeds, "seed", "")
+
+    var seedsFile string
+    flag.StringVar(&seedsFile, "seeds-file", "", "")
 
     var helpFlag bool
     flag.BoolVar(&helpFlag, "h", false, "")
@@ -25,15 +34,131 @@ func main() {
     flag.BoolVar(&publicFlag, "p", false, "")
     flag.BoolVar(&publicFlag, "public", false, "")
 
-    flag.Parse()
+    var externalAddr string
+    flag.StringVar(&externalAddr, "external-addr", "", "")
+
+    var dataDir string
+    flag.StringVar(&dataDir, "d", "", "")
+    flag.StringVar(&dataDir, "datadir", "", "")
+
+    var apiAddr string
+    flag.StringVar(&apiAddr, "api-addr", "", "")
+
+    var logFile string
+    flag.StringVar(&logFile, "log-file", "", "")
+
+    var tuiFlag bool
+    flag.BoolVar(&tuiFlag, "t", false, "")
+    flag.BoolVar(&tuiFlag, "tui", false, "")
+
+    var format string
+    flag.StringVar(&format, "format", "", "")
+
+    var devFlag bool
+    flag.BoolVar(&devFlag, "dev", false, "")
 
-    listenPort   = ":" + listenPort
+    var configPath string
+    flag.StringVar(&configPath, "c", "", "")
+    flag.StringVar(&configPath, "config", "", "")
+
+    flag.Parse()
 
     if helpFlag {
         showGlobalHelp()
         return
     }
 
+    // defaults, then the config file, then the environment, then the flags given
+    config := defaultConfig()
+    if configPath != "" {
+        if err := loadConfigFile(&config, configPath); err != nil {
+            fmt.Println("Unable to read the config file:")
+            fmt.Println(err)
+            os.Exit(1)
+        }
+    }
+    if err := applyConfigEnv(&config, os.LookupEnv); err != nil {
+        fmt.Println("Invalid environment setting:")
+        fmt.Println(err)
+        os.Exit(1)
+    }
+    flag.Visit(func(f *flag.Flag) {
+        switch f.Name {
+            case "l", "listen":  config.Network.ListenPort = listenPort
+            case "s", "seed":    config.Network.Seeds      = append(config.Network.Seeds, seeds...) // added to the seeds of the config
+            case "seeds-file":   config.Network.SeedsFile  = seedsFile
+            case "p", "public":  config.Network.Public     = publicFlag
+            case "d", "datadir": config.Storage.DataDir    = dataDir
+            case "external-addr": config.Network.ExternalAddr = externalAddr
+            case "log-file":     config.Logging.File       = logFile
+            case "api-addr":     config.API.Address        = apiAddr
+            case "format":       config.Output.Format      = format
+        }
+    })
+    if devFlag { // a fresh blockchain every run, sealing blocks as soon as there is something to seal
+        config.Consensus.Engine = "dev"
+        config.Storage.DataDir  = ""
+    }
+    if err := validateConfig(config); err != nil {
+        fmt.Println("Invalid configuration:")
+        fmt.Println(err)
+        os.Exit(1)
+    }
+    difficulty         = config.Mining.Difficulty
+    consensus, _       = newConsensusEngine(config.Consensus.Engine) // known, as checked by validateConfig
+    genesisAuthorities = parseAuthorities(config.Consensus.Authorities)
+    outputFormat       = config.Output.Format
+
+    // logs go to stderr or the log file, leaving stdout to the command line
+    logOutput, err := setupLogging(config.Logging)
+    if err != nil {
+        fmt.Println("Unable to open the log file:")
+        fmt.Println(err)
+        os.Exit(1)
+    }
+    if logOutput != nil {
+        defer logOutput.Close()
+    }
+
+    // stop the node cleanly on ctrl-c or when the system asks it to
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+
     myNode := newNode()
-    myNode.run(listenPort, seedData, publicFlag)
+    myNode.config = config
+    if !tuiFlag {
+        myNode.run(ctx, ":" + config.Network.ListenPort, config.Network.Seeds, config.Network.Public)
+        return
+    }
+
+    // the TUI takes over the terminal before the node starts printing
+    tui, err := newNodeTUI(config.Logging, logOutput)
+    if err != nil {
+        fmt.Println("Unable to start the TUI:")
+        fmt.Println(err)
+        os.Exit(1)
+    }
+    ctx, stopNode := context.WithCancel(ctx)
+    stopped := make(chan bool)
+    go func() {
+        myNode.run(ctx, ":" + config.Network.ListenPort, config.Network.Seeds, config.Network.Public)
+        close(stopped)
+    }()
+    if err := tui.run(myNode, stopNode, stopped); err != nil {
+        stopNode()
+        fmt.Fprintln(os.Stderr, err)
+    }
+    <-stopped
+}
+
+// -s may be given more than once, or with a comma separated list of seeds
+type seedFlag []string
+
+func (seeds *seedFlag) String() string {
+    return strings.Join(*seeds, ",")
+}
+
+func (seeds *seedFlag) Set(value string) error {
+    *seeds = append(*seeds, parseSeedList([]string{value})...)
+    return nil
 }
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+/*
+manifest.go hashes documents too large to hold in memory, and whole
+directories.  Files are streamed through the hash, printing progress as
+they go.  A directory is turned into a manifest: every regular file is
+hashed, and a merkle tree over (path, file hash) pairs, sorted by path,
+gives a single root to anchor in one packet.  Each manifest entry keeps
+its merkle proof, so one file can later be shown to be part of the
+anchored directory without publishing the others.
+*/
+
+const manifestContentType = "application/vnd.go-blockchain.manifest+json"
+const progressInterval    = 64 << 20 // report progress every 64MiB
+
+type Manifest struct {
+	Root      []byte
+	Algorithm string
+	Size      uint64
+	Files     []ManifestEntry
+}
+
+type ManifestEntry struct {
+	Path  string // relative to the manifest directory, always with forward slashes
+	Hash  []byte
+	Size  uint64
+	Proof []MerkleStep
+}
+
+// wraps a reader and prints how much of it has been read
+type progressReader struct {
+	reader     io.Reader
+	out        io.Writer
+	name       string
+	total      uint64
+	read       uint64
+	lastReport uint64
+}
+
+func (p *progressReader) Read(buffer []byte) (int, error) {
+	count, err := p.reader.Read(buffer)
+	p.read = p.read + uint64(count)
+	if p.read-p.lastReport >= progressInterval || (err == io.EOF && p.lastReport != 0) {
+		p.lastReport = p.read
+		fmt.Fprintf(p.out, "  hashing %v: %v of %v MiB (%v%%)\n", p.name, p.read>>20, p.total>>20, p.read*100/maxUint64(p.total, 1))
+	}
+	return count, err
+}
+
+func maxUint64(a, b uint64) uint64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// hashes a file without reading it all into memory, progress may be nil
+func hashFile(filePath string, algorithm string, progress io.Writer) ([]byte, uint64, error) {
+	newHash, known := hashAlgorithms[algorithm]
+	if !known {
+		return nil, 0, fmt.Errorf("unknown hash algorithm %q", algorithm)
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var reader io.Reader = file
+	if progress != nil {
+		reader = &progressReader{reader: file, out: progress, name: filePath, total: uint64(info.Size())}
+	}
+
+	h := newHash()
+	size, err := io.Copy(h, reader)
+	if err != nil {
+		return nil, 0, err
+	}
+	return h.Sum(nil), uint64(size), nil
+}
+
+func manifestLeafHash(path string, fileHash []byte) []byte {
+	return merkleLeafHash(append(lengthPrefixed([]byte(path)), fileHash...))
+}
+
+// hashes every regular file below root; symlinks and other special files are skipped
+func buildManifest(root string, algorithm string, progress io.Writer) (*Manifest, error) {
+	manifest := &Manifest{Algorithm: algorithm, Files: []ManifestEntry{}}
+
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		relativePath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		fileHash, size, err := hashFile(path, algorithm, progress)
+		if err != nil {
+			return err
+		}
+		manifest.Files = append(manifest.Files, ManifestEntry{Path: filepath.ToSlash(relativePath), Hash: fileHash, Size: size})
+		manifest.Size  = manifest.Size + size
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(manifest.Files) == 0 {
+		return nil, errors.New("directory has no files to anchor")
+	}
+
+	// WalkDir is lexical per directory, sort the full paths so the order never depends on the platform
+	sort.Slice(manifest.Files, func(i, j int) bool { return manifest.Files[i].Path < manifest.Files[j].Path })
+
+	leaves := [][]byte{}
+	for _, file := range manifest.Files {
+		leaves = append(leaves, manifestLeafHash(file.Path, file.Hash))
+	}
+	manifest.Root = merkleRoot(leaves)
+	for i := range manifest.Files {
+		manifest.Files[i].Proof = merkleProof(leaves, i)
+	}
+	return manifest, nil
+}
+
+func verifyManifestEntry(entry ManifestEntry, root []byte) bool {
+	return verifyMerkleProof(manifestLeafHash(entry.Path, entry.Hash), entry.Proof, root)
+}
+
+func (manifest *Manifest) findEntry(path string) (ManifestEntry, bool) {
+	for _, entry := range manifest.Files {
+		if entry.Path == filepath.ToSlash(path) {
+			return entry, true
+		}
+	}
+	return ManifestEntry{}, false
+}
+
+func writeManifest(manifest *Manifest, filePath string) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, data, 0644)
+}
+
+func readManifest(filePath string) (*Manifest, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// signs the manifest root as a packet, recording it is a manifest and the total size of its files
+func createManifestPacket(manifest *Manifest, keys Keypair, memo string) Packet {
+	packet := Packet{Hash:          manifest.Root,
+	                 Owner:         keys.Public,
+	                 HashAlgorithm: defaultHashAlgorithm,
+	                 ContentType:   manifestContentType,
+	                 Size:          manifest.Size,
+	                 Memo:          memo}
+	packet.Signature = signHash(packetSigningHash(packet), keys)
+	return packet
+}
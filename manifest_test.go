@@ -0,0 +1,88 @@
+package main
+
+import(
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func createTestDirectory(t *testing.T) string {
+	dir := t.TempDir()
+	files := map[string]string{"readme.txt":      "release notes",
+							   "bin/tool":        "binary",
+							   "docs/guide.md":   "guide",
+							   "docs/api/ref.md": "reference"}
+	for path, content := range files {
+		fullPath := filepath.Join(dir, path)
+		os.MkdirAll(filepath.Dir(fullPath), 0755)
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestHashFile(t *testing.T){
+	fileHash, size, err := hashFile("document.txt", defaultHashAlgorithm, nil)
+	document, _ := os.ReadFile("document.txt")
+	if err != nil || string(fileHash) != string(hashDocument(document)) || size != uint64(len(document)) {
+		t.Error("streamed hash differs from hashing the whole document")
+	}
+
+	if _, _, err := hashFile("does-not-exist.txt", defaultHashAlgorithm, nil); err == nil {
+		t.Error("hashes a missing file")
+	}
+}
+
+func TestBuildManifest(t *testing.T){
+	dir := createTestDirectory(t)
+	manifest, err := buildManifest(dir, defaultHashAlgorithm, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Files) != 4 || manifest.Files[0].Path != "bin/tool" || manifest.Size != 33 {
+		t.Errorf("manifest has wrong files %v", manifest.Files)
+	}
+
+	// every file proves its membership, and only its own
+	for _, entry := range manifest.Files {
+		if !verifyManifestEntry(entry, manifest.Root) {
+			t.Errorf("fails to verify %v", entry.Path)
+		}
+		entry.Hash = hashDocument([]byte("tampered"))
+		if verifyManifestEntry(entry, manifest.Root) {
+			t.Errorf("verifies tampered %v", entry.Path)
+		}
+	}
+
+	// the root is deterministic, and changes with any file
+	again, _ := buildManifest(dir, defaultHashAlgorithm, nil)
+	if string(again.Root) != string(manifest.Root) {
+		t.Error("manifest root is not deterministic")
+	}
+	os.WriteFile(filepath.Join(dir, "docs/guide.md"), []byte("guide v2"), 0644)
+	changed, _ := buildManifest(dir, defaultHashAlgorithm, nil)
+	if string(changed.Root) == string(manifest.Root) {
+		t.Error("manifest root does not change with file contents")
+	}
+
+	// manifests survive being written and read back
+	manifestPath := filepath.Join(t.TempDir(), "release.manifest.json")
+	if err := writeManifest(manifest, manifestPath); err != nil {
+		t.Fatal(err)
+	}
+	read, err := readManifest(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, found := read.findEntry("docs/api/ref.md")
+	if !found || !verifyManifestEntry(entry, read.Root) {
+		t.Error("fails to verify entry of a manifest read from disk")
+	}
+
+	// the root is anchored as a valid packet
+	keys01 := GenerateNewKeypair()
+	if !verifyPacket(createManifestPacket(manifest, *keys01, "v1.0")) {
+		t.Error("fails to approve manifest packet")
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"crypto/sha256"
+)
+
+/*
+merkle.go builds binary merkle trees over lists of hashes, and proofs
+that a single leaf is part of a tree.  Leaves and interior nodes are
+hashed with different prefixes so a leaf can never be passed off as an
+interior node.  A node without a sibling is carried up a level unchanged
+rather than paired with itself.
+*/
+
+type MerkleStep struct {
+	Hash []byte
+	Left bool // true if Hash is the left sibling
+}
+
+func merkleLeafHash(data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0})
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func merkleNodeHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{1})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// returns the root of the tree over the given leaf hashes
+func merkleRoot(leaves [][]byte) []byte {
+	if len(leaves) == 0 {
+		return merkleLeafHash([]byte{})
+	}
+	level := leaves
+	for len(level) > 1 {
+		level = nextMerkleLevel(level)
+	}
+	return level[0]
+}
+
+func nextMerkleLevel(level [][]byte) [][]byte {
+	next := [][]byte{}
+	for i := 0; i < len(level); i += 2 {
+		if i+1 == len(level) {
+			next = append(next, level[i])
+		} else {
+			next = append(next, merkleNodeHash(level[i], level[i+1]))
+		}
+	}
+	return next
+}
+
+// returns the siblings needed to recompute the root from the leaf at index
+func merkleProof(leaves [][]byte, index int) []MerkleStep {
+	proof := []MerkleStep{}
+	level := leaves
+	for len(level) > 1 {
+		sibling := index ^ 1
+		if sibling < len(level) {
+			proof = append(proof, MerkleStep{Hash: level[sibling], Left: sibling < index})
+		}
+		level = nextMerkleLevel(level)
+		index = index / 2
+	}
+	return proof
+}
+
+func merkleRootFromProof(leaf []byte, proof []MerkleStep) []byte {
+	hash := leaf
+	for _, step := range proof {
+		if step.Left {
+			hash = merkleNodeHash(step.Hash, hash)
+		} else {
+			hash = merkleNodeHash(hash, step.Hash)
+		}
+	}
+	return hash
+}
+
+func verifyMerkleProof(leaf []byte, proof []MerkleStep, root []byte) bool {
+	return string(merkleRootFromProof(leaf, proof)) == string(root)
+}
@@ -0,0 +1,43 @@
+package main
+
+import(
+	"fmt"
+	"testing"
+)
+
+func TestMerkleProof(t *testing.T){
+	// odd and even sized trees, including a single leaf
+	for size := 1; size <= 9; size++ {
+		leaves := [][]byte{}
+		for i := 0; i < size; i++ {
+			leaves = append(leaves, merkleLeafHash([]byte(fmt.Sprintf("leaf %v", i))))
+		}
+		root := merkleRoot(leaves)
+
+		for i := range leaves {
+			proof := merkleProof(leaves, i)
+			if !verifyMerkleProof(leaves[i], proof, root) {
+				t.Errorf("fails to verify leaf %v of %v", i, size)
+			}
+			if size > 1 && verifyMerkleProof(leaves[(i+1)%size], proof, root) {
+				t.Errorf("verifies wrong leaf with proof for leaf %v of %v", i, size)
+			}
+		}
+	}
+}
+
+func TestMerkleRootChangesWithLeaves(t *testing.T){
+	leaves := [][]byte{merkleLeafHash([]byte("a")), merkleLeafHash([]byte("b")), merkleLeafHash([]byte("c"))}
+	root := merkleRoot(leaves)
+
+	reordered := [][]byte{leaves[1], leaves[0], leaves[2]}
+	if string(merkleRoot(reordered)) == string(root) {
+		t.Error("root does not depend on the order of leaves")
+	}
+
+	// the data of an interior node does not hash to the same value as a leaf
+	interiorData := append(append([]byte{}, leaves[0]...), leaves[1]...)
+	if string(merkleLeafHash(interiorData)) == string(merkleNodeHash(leaves[0], leaves[1])) {
+		t.Error("interior node can be passed off as a leaf")
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/sha3"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+/*
+metadata.go handles the optional metadata a packet may carry: the
+algorithm its document was hashed with, the document's MIME type and
+size, and a short memo.  When any metadata is set the owner signs a
+digest covering both the document hash and the metadata, so none of it
+can be changed without invalidating the packet.  The size limits below
+are enforced when validating blocks.
+*/
+
+const defaultHashAlgorithm = "sha256"
+const maxContentTypeLength = 127
+const maxMemoLength        = 140
+
+var hashAlgorithms = map[string]func() hash.Hash{
+	"sha256":      sha256.New,
+	"sha512":      sha512.New,
+	"sha3-256":    func() hash.Hash { return sha3.New256() },
+	"blake2b-256": func() hash.Hash { h, _ := blake2b.New256(nil); return h },
+}
+
+func hasPacketMetadata(packet Packet) bool {
+	return packet.HashAlgorithm != "" || packet.ContentType != "" || packet.Size != 0 || packet.Memo != ""
+}
+
+// the digest the owners sign; the document hash itself for packets without metadata
+func packetSigningHash(packet Packet) []byte {
+	if !hasPacketMetadata(packet) {
+		return packet.Hash
+	}
+	h := sha256.New()
+
+	sizeBytes := make([]byte, 8)
+	binary.LittleEndian.PutUint64(sizeBytes, packet.Size)
+
+	h.Write([]byte("packet metadata"))
+	h.Write(lengthPrefixed(packet.Hash))
+	h.Write(lengthPrefixed([]byte(packet.HashAlgorithm)))
+	h.Write(lengthPrefixed([]byte(packet.ContentType)))
+	h.Write(sizeBytes)
+	h.Write(lengthPrefixed([]byte(packet.Memo)))
+
+	return h.Sum(nil)
+}
+
+// checks the metadata is within the limits every node enforces
+func verifyPacketMetadata(packet Packet) bool {
+	algorithm := packet.HashAlgorithm
+	if algorithm == "" {
+		algorithm = defaultHashAlgorithm
+	}
+	newHash, known := hashAlgorithms[algorithm]
+	if !known {
+		return false
+	}
+	// packets from before metadata may anchor a hash of any length
+	if hasPacketMetadata(packet) && len(packet.Hash) != newHash().Size() {
+		return false
+	}
+
+	if len(packet.ContentType) > maxContentTypeLength {
+		return false
+	}
+	if packet.ContentType != "" {
+		if _, _, err := mime.ParseMediaType(packet.ContentType); err != nil {
+			return false
+		}
+	}
+
+	return len(packet.Memo) <= maxMemoLength
+}
+
+func hashDocumentWithAlgorithm(document []byte, algorithm string) []byte {
+	newHash, known := hashAlgorithms[algorithm]
+	if !known {
+		return nil
+	}
+	h := newHash()
+	h.Write(document)
+	return h.Sum(nil)
+}
+
+// guesses the MIME type from the extension, or else from the first bytes of the file
+func detectContentType(filePath string) string {
+	if contentType := mime.TypeByExtension(filepath.Ext(filePath)); contentType != "" {
+		return contentType
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	head := make([]byte, 512)
+	count, _ := io.ReadFull(file, head)
+	return http.DetectContentType(head[:count])
+}
+
+func createPacketWithMetadata(filePath string, keys Keypair, algorithm string, memo string) Packet {
+	documentHash, size, err := hashFile(filePath, algorithm, os.Stdout)
+	if err != nil {
+		fmt.Println(err)
+	}
+	packet := Packet{Hash:          documentHash,
+	                 Owner:         keys.Public,
+	                 HashAlgorithm: algorithm,
+	                 ContentType:   detectContentType(filePath),
+	                 Size:          size,
+	                 Memo:          memo}
+	packet.Signature = signHash(packetSigningHash(packet), keys)
+	return packet
+}
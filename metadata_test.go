@@ -0,0 +1,84 @@
+package main
+
+import(
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestPacketMetadata(t *testing.T){
+	keys01 := GenerateNewKeypair()
+
+	for algorithm := range hashAlgorithms {
+		packet := createPacketWithMetadata("document.txt", *keys01, algorithm, "first draft")
+		if !verifyPacket(packet) {
+			t.Errorf("fails to approve valid packet hashed with %v", algorithm)
+		}
+	}
+
+	packet      := createPacketWithMetadata("document.txt", *keys01, "sha3-256", "first draft")
+	document, _ := os.ReadFile("document.txt")
+	if packet.ContentType != "text/plain; charset=utf-8" || packet.Size != uint64(len(document)) {
+		t.Errorf("records wrong content type %q or size %v", packet.ContentType, packet.Size)
+	}
+
+	// metadata is covered by the signature
+	packet01 := packet
+	packet01.Memo = "final draft"
+	if verifyPacket(packet01) {
+		t.Error("approves packet whose memo was changed after signing")
+	}
+	packet02 := packet
+	packet02.Size = 1
+	if verifyPacket(packet02) {
+		t.Error("approves packet whose size was changed after signing")
+	}
+
+	// limits are enforced
+	packet03 := createPacketWithMetadata("document.txt", *keys01, "sha256", string(make([]byte, maxMemoLength+1)))
+	if verifyPacket(packet03) {
+		t.Error("approves packet with memo over the size limit")
+	}
+	packet04 := packet
+	packet04.HashAlgorithm = "sha256"
+	packet04.Hash = packet04.Hash[:20]
+	if verifyPacketMetadata(packet04) {
+		t.Error("approves hash of the wrong length for its algorithm")
+	}
+	packet05 := packet
+	packet05.HashAlgorithm = "md5"
+	if verifyPacketMetadata(packet05) {
+		t.Error("approves unknown hash algorithm")
+	}
+	packet06 := packet
+	packet06.ContentType = "not a mime type"
+	if verifyPacketMetadata(packet06) {
+		t.Error("approves invalid content type")
+	}
+
+	// plain packets keep signing the document hash alone
+	plain := createPacket("document.txt", *keys01)
+	if string(packetSigningHash(plain)) != string(plain.Hash) || !verifyPacket(plain) {
+		t.Error("plain packets are no longer signed over the document hash")
+	}
+
+	// packets from before metadata are valid whatever the length of their hash
+	legacyHash := []byte("a hash from before metadata")
+	legacy     := Packet{Hash: legacyHash, Owner: keys01.Public, Signature: signHash(legacyHash, *keys01)}
+	if !verifyPacket(legacy) {
+		t.Error("no longer approves a packet without metadata whose hash is not a sha256")
+	}
+}
+
+func TestHashDocumentWithAlgorithm(t *testing.T){
+	// sha3-256 of the empty string
+	if fmt.Sprintf("%x", hashDocumentWithAlgorithm([]byte{}, "sha3-256")) != "a7ffc6f8bf1ed76651c14756a061d662f580ff4de43b49fa82d80a4b80f8434a" {
+		t.Error("sha3-256 gives the wrong hash")
+	}
+	if string(hashDocumentWithAlgorithm([]byte("doc"), "sha256")) != string(hashDocument([]byte("doc"))) {
+		t.Error("sha256 differs from hashDocument")
+	}
+	if hashDocumentWithAlgorithm([]byte("doc"), "md5") != nil {
+		t.Error("hashes with an unknown algorithm")
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+metrics.go keeps the numbers that show what a running node is doing and
+writes them in the Prometheus text format, served at /metrics by the
+HTTP API.  Counters are kept for the whole process, as connections and
+the miner do not know which node they belong to, while gauges such as
+the chain height are read from the node when the metrics are scraped.
+*/
+
+const metricsPrefix = "goblockchain_"
+
+type counter struct {
+	value atomic.Uint64
+}
+
+func (c *counter) add(delta uint64) {
+	c.value.Add(delta)
+}
+
+func (c *counter) get() float64 {
+	return float64(c.value.Load())
+}
+
+type gauge struct {
+	bits atomic.Uint64 // a float64
+}
+
+func (g *gauge) set(value float64) {
+	g.bits.Store(math.Float64bits(value))
+}
+
+func (g *gauge) get() float64 {
+	return math.Float64frombits(g.bits.Load())
+}
+
+var (
+	bytesSent      counter // by writeCommunication
+	bytesReceived  counter // by listenToConn
+	rejectedBlocks counter // blocks that failed isValidNextBlock
+	hashesTried    counter // by the miner
+	hashRate       gauge   // hashes a second, over the last second of mining
+)
+
+type metric struct {
+	name  string
+	help  string
+	kind  string // counter or gauge
+	value func() float64
+}
+
+type metricsRegistry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+}
+
+func newMetricsRegistry() *metricsRegistry {
+	return &metricsRegistry{metrics: map[string]metric{}}
+}
+
+func (r *metricsRegistry) register(name, help, kind string, value func() float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics[metricsPrefix+name] = metric{name: metricsPrefix + name, help: help, kind: kind, value: value}
+}
+
+// writes every metric in the Prometheus text exposition format, sorted by name
+func (r *metricsRegistry) writeTo(out io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := []string{}
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		m := r.metrics[name]
+		if _, err := fmt.Fprintf(out, "# HELP %v %v\n# TYPE %v %v\n%v %v\n", m.name, m.help, m.name, m.kind, m.name, formatMetricValue(m.value())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatMetricValue(value float64) string {
+	if value == math.Trunc(value) && math.Abs(value) < 1e15 {
+		return fmt.Sprintf("%d", int64(value))
+	}
+	return fmt.Sprintf("%g", value)
+}
+
+// the metrics of a node, read from its state when scraped
+func newNodeMetrics(n *Node) *metricsRegistry {
+	r := newMetricsRegistry()
+	r.register("chain_height", "Index of the last block of the node's blockchain.", "gauge", func() float64 {
+		return float64(n.getBlockchain().getLastBlock().Index)
+	})
+	r.register("peers", "Number of connected peers.", "gauge", func() float64 {
+		return float64(len(n.getRemoteAddresses()))
+	})
+	r.register("mempool_packets", "Packets waiting to be mined.", "gauge", func() float64 {
+		return float64(len(n.getCurPacketList()))
+	})
+	r.register("mempool_records", "Records waiting to be mined.", "gauge", func() float64 {
+		return float64(len(n.getCurRecordList()))
+	})
+	r.register("miners_running", "Miners searching for the next block.", "gauge", func() float64 {
+		return float64(runningMiners.Load())
+	})
+	r.register("miner_hash_rate", "Block hashes tried a second by the miner.", "gauge", hashRate.get)
+	r.register("miner_hashes_total", "Block hashes tried by the miner.", "counter", hashesTried.get)
+	r.register("rejected_blocks_total", "Blocks rejected by isValidNextBlock.", "counter", rejectedBlocks.get)
+	r.register("sent_bytes_total", "Bytes of communications sent to peers.", "counter", bytesSent.get)
+	r.register("received_bytes_total", "Bytes of communications received from peers.", "counter", bytesReceived.get)
+	return r
+}
+
+// counts the bytes going through a connection
+type countingConn struct {
+	net.Conn
+	sent     *counter
+	received *counter
+}
+
+func (c countingConn) Write(buffer []byte) (int, error) {
+	count, err := c.Conn.Write(buffer)
+	c.sent.add(uint64(count))
+	return count, err
+}
+
+func (c countingConn) Read(buffer []byte) (int, error) {
+	count, err := c.Conn.Read(buffer)
+	c.received.add(uint64(count))
+	return count, err
+}
+
+// measures the miner's hash rate, updating hashRate about once a second
+type hashRateMeter struct {
+	start  time.Time
+	hashes uint64
+}
+
+func (m *hashRateMeter) tried() {
+	hashesTried.add(1)
+	m.hashes = m.hashes + 1
+	if m.start.IsZero() {
+		m.start = time.Now()
+		return
+	}
+	if m.hashes%1024 != 0 { // only look at the clock now and then
+		return
+	}
+	if elapsed := time.Since(m.start); elapsed >= time.Second {
+		hashRate.set(float64(m.hashes) / elapsed.Seconds())
+		m.start  = time.Now()
+		m.hashes = 0
+	}
+}
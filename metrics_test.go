@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsRegistryFormat(t *testing.T) {
+	r := newMetricsRegistry()
+	r.register("b_total", "A counter.", "counter", func() float64 { return 3 })
+	r.register("a", "A gauge.", "gauge", func() float64 { return 1.5 })
+
+	out := &bytes.Buffer{}
+	r.writeTo(out)
+	expected := "# HELP goblockchain_a A gauge.\n# TYPE goblockchain_a gauge\ngoblockchain_a 1.5\n" +
+	            "# HELP goblockchain_b_total A counter.\n# TYPE goblockchain_b_total counter\ngoblockchain_b_total 3\n"
+	if out.String() != expected {
+		t.Error("Metrics are not in the Prometheus text format:\n" + out.String())
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	useDifficulty(t, 4294967295) // all hashes pass
+	n := newNode()
+
+	keys   := GenerateNewKeypair()
+	hash   := hashDocument([]byte("metrics"))
+	n.curPacketList = []Packet{{Hash: hash, Owner: keys.Public, Signature: signHash(hash, *keys)}}
+
+	// a block that does not follow the genesis block is rejected
+	rejectedBefore := rejectedBlocks.get()
+	n.handleBlockWrapper(&BlockWrapper{Block: Block{Index: 1, PrevHash: []byte("wrong"), Hash: []byte("wrong")}})
+	if rejectedBlocks.get() != rejectedBefore+1 {
+		t.Error("Rejected block was not counted")
+	}
+
+	response := httptest.NewRecorder()
+	n.newAPIHandler().ServeHTTP(response, httptest.NewRequest("GET", "/metrics", nil))
+	body := response.Body.String()
+	if response.Code != 200 || !strings.HasPrefix(response.Header().Get("Content-Type"), "text/plain") {
+		t.Error("/metrics did not answer with plain text")
+	}
+	for _, line := range []string{"goblockchain_chain_height 0\n", "goblockchain_peers 0\n", "goblockchain_mempool_packets 1\n", "# TYPE goblockchain_rejected_blocks_total counter\n"} {
+		if !strings.Contains(body, line) {
+			t.Error("/metrics is missing " + strings.TrimSpace(line))
+		}
+	}
+}
+
+func TestCountingConn(t *testing.T) {
+	connIn, connOut := net.Pipe()
+	defer connIn.Close()
+	defer connOut.Close()
+
+	sent     := &counter{}
+	received := &counter{}
+	go func() {
+		countingConn{Conn: connOut, sent: sent, received: &counter{}}.Write([]byte("hello"))
+		connOut.Close()
+	}()
+	io.ReadAll(countingConn{Conn: connIn, sent: &counter{}, received: received})
+
+	if sent.get() != 5 || received.get() != 5 {
+		t.Error("countingConn did not count the bytes through the connection")
+	}
+}
@@ -1,49 +1,79 @@
 package main 
 
 import (
-	"fmt"
 	"encoding/binary"
+	"sync/atomic"
 )
 
 // const difficulty = 200 // can't use constant because its impossible to generate low enough block hashes for tests
-var difficulty uint32
-
-func mineBlock(blockWrapperChannel chan *BlockWrapper, n *Node){
-	fmt.Println("-> begin mining...")
-
-	var blockHashAsInt uint32
-	var nonce          uint32
-	nonce          = 0
-	blockHashAsInt = 4294967295 // max value ensures we will enter mining loop
-
-	var lastBlock      Block
-	var block          Block
-	var currentPackets []Packet
-	var blockHash      []byte
-
-	for blockHashAsInt > difficulty {
-		lastBlock 	    = n.blockchain.getLastBlock()
-		currentPackets  = n.curPacketList
-		block 		    = Block{Index:    lastBlock.Index + 1,
-								Nonce:    nonce,
-								PrevHash: lastBlock.Hash,
-								Data:     currentPackets,
-								Hash:     []byte{}}
-		blockHash       = block.calcHashForBlock(nonce)
-		blockHashAsInt  = binary.LittleEndian.Uint32(blockHash)
-		nonce           = nonce + 1
-	}
+var difficulty uint32 = 200
 
-	block.Hash    = blockHash
-	blockWrapper := &BlockWrapper{Block: block, Sender: n.address}
+var runningMiners atomic.Int32
 
-	blockWrapperChannel <- blockWrapper
-	fmt.Println()
-    mineBlock(blockWrapperChannel, n)
+// starts a miner that runs until the node shuts down
+func startMining(n *Node){
+	n.miners.Add(1)
+	go mineBlock(n)
 }
 
+func mineBlock(n *Node){
+	defer n.miners.Done()
+	runningMiners.Add(1)
+	defer runningMiners.Add(-1)
+	for {
+		minerLog.Info("begin mining")
+		block, mined := consensus.seal(n)
+		if !mined {
+			minerLog.Info("stopped mining")
+			hashRate.set(0)
+			return
+		}
+		minerLog.Info("mined block", "index", block.Index, "nonce", block.Nonce)
+		blockWrapper := &BlockWrapper{Block: block, Sender: n.getAddress()}
 
+		select {
+		case n.blockWrapperChannel <- blockWrapper:
+		case <-n.ctx.Done():
+			minerLog.Info("stopped mining")
+			hashRate.set(0)
+			return
+		}
+	}
+}
 
+// proof of work: the block hash, read as a little endian uint32, must be below the difficulty
+type powEngine struct{}
+
+func (powEngine) prepare(n *Node, blockchain Blockchain, block *Block) {
+	block.Nonce = 0
+}
 
+// searches for a nonce giving a hash below the difficulty, rebuilding the
+// block each try so it holds the latest tip and mempool, until the node shuts down
+func (engine powEngine) seal(n *Node) (Block, bool){
+	var meter hashRateMeter
+	for nonce := uint32(0); ; nonce++ {
+		if n.ctx.Err() != nil {
+			return Block{}, false
+		}
+		block      := nextBlock(n, n.getBlockchain())
+		block.Nonce = nonce
+		block.Hash  = block.calcHashForBlock(nonce)
+		meter.tried()
+		if engine.verifyHeader(&block) {
+			return block, true
+		}
+	}
+}
 
+func (powEngine) verifyHeader(block *Block) bool {
+	if len(block.Hash) < 4 {
+		return false
+	}
+	return binary.LittleEndian.Uint32(block.Hash) < difficulty
+}
 
+// the hashes it takes on average to find a block below the difficulty
+func (powEngine) work(blockchain Blockchain, block *Block) uint64 {
+	return (uint64(1) << 32) / (uint64(difficulty) + 1)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+/*
+multisig.go handles packets jointly owned by several keys.  An m-of-n
+packet lists all n co-owners and is valid once at least m of them have
+signed.  Each co-owner signs the same digest, which commits to the
+document hash, the threshold and the ordered list of owners, so a
+signature can only be used for the packet it was made for.  Co-owners
+can therefore sign on separate machines and the signatures be collected
+afterwards.
+*/
+
+func isMultiSigPacket(packet Packet) bool {
+	return len(packet.Owners) > 0
+}
+
+// the digest every co-owner signs, documentHash is packetSigningHash for packets with metadata
+func multiSigDigest(documentHash []byte, threshold uint32, owners [][]byte) []byte {
+	h := sha256.New()
+
+	thresholdBytes := make([]byte, 4)
+	binary.LittleEndian.PutUint32(thresholdBytes, threshold)
+
+	h.Write(lengthPrefixed(documentHash))
+	h.Write(thresholdBytes)
+	for _, owner := range owners {
+		h.Write(lengthPrefixed(owner))
+	}
+
+	return h.Sum(nil)
+}
+
+// prefixes variable length data with its length so concatenations are unambiguous
+func lengthPrefixed(data []byte) []byte {
+	prefixed := make([]byte, 4, 4+len(data))
+	binary.LittleEndian.PutUint32(prefixed, uint32(len(data)))
+	return append(prefixed, data...)
+}
+
+// produces one co-owner's partial signature over the packet digest
+func signMultiSig(documentHash []byte, threshold uint32, owners [][]byte, keys Keypair) []byte {
+	return signHash(multiSigDigest(documentHash, threshold, owners), keys)
+}
+
+func createMultiSigPacket(documentHash []byte, threshold uint32, owners, signatures [][]byte) Packet {
+	return Packet{Hash:       documentHash,
+	              Threshold:  threshold,
+	              Owners:     owners,
+	              Signatures: signatures}
+}
+
+func verifyMultiSigPacket(packet Packet) bool {
+	if len(packet.Owner) != 0 || len(packet.Signature) != 0 {
+		return false // a packet is either single or multi-signature, not both
+	}
+	if packet.Threshold == 0 || int(packet.Threshold) > len(packet.Owners) {
+		return false
+	}
+	if len(packet.Signatures) != len(packet.Owners) {
+		return false
+	}
+
+	digest := multiSigDigest(packetSigningHash(packet), packet.Threshold, packet.Owners)
+	seenOwners := map[string]bool{}
+	validSignatures := uint32(0)
+	for i, owner := range packet.Owners {
+		if seenOwners[string(owner)] {
+			return false // listing an owner twice would let them sign twice
+		}
+		seenOwners[string(owner)] = true
+
+		signature := packet.Signatures[i]
+		if len(signature) == 0 {
+			continue // this co-owner has not signed
+		}
+		if !SignatureVerify(owner, signature, digest) {
+			return false
+		}
+		validSignatures = validSignatures + 1
+	}
+
+	return validSignatures >= packet.Threshold
+}
+
+// true if the key is the owner, or one of the co-owners, of the packet
+func packetHasOwner(packet Packet, publicKey []byte) bool {
+	if string(packet.Owner) == string(publicKey) && len(publicKey) != 0 {
+		return true
+	}
+	for _, owner := range packet.Owners {
+		if string(owner) == string(publicKey) {
+			return true
+		}
+	}
+	return false
+}
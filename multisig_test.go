@@ -0,0 +1,96 @@
+package main
+
+import(
+	"testing"
+)
+
+func createTestMultiSigPacket(threshold uint32, signers int) (Packet, []*Keypair) {
+	keys := []*Keypair{GenerateNewKeypair(), GenerateNewKeypair(), GenerateNewKeypair()}
+	owners := [][]byte{keys[0].Public, keys[1].Public, keys[2].Public}
+
+	documentHash, _, _ := hashFile("document.txt", defaultHashAlgorithm, nil)
+	signatures := [][]byte{{}, {}, {}}
+	for i := 0; i < signers; i++ {
+		signatures[i] = signMultiSig(documentHash, threshold, owners, *keys[i])
+	}
+
+	return createMultiSigPacket(documentHash, threshold, owners, signatures), keys
+}
+
+func TestVerifyMultiSigPacket(t *testing.T){
+	packet, keys := createTestMultiSigPacket(2, 2)
+	if !verifyPacketSignature(packet) {
+		t.Error("fails to approve 2 of 3 packet with 2 signatures")
+	}
+
+	// not enough signatures
+	packet01, _ := createTestMultiSigPacket(2, 1)
+	if verifyPacketSignature(packet01) {
+		t.Error("approves 2 of 3 packet with 1 signature")
+	}
+
+	// signature moved to the wrong co-owner
+	packet02 := createMultiSigPacket(packet.Hash, 2, packet.Owners, [][]byte{packet.Signatures[1], packet.Signatures[0], {}})
+	if verifyPacketSignature(packet02) {
+		t.Error("approves packet with signatures in the wrong place")
+	}
+
+	// signatures are bound to the threshold they were made for
+	packet03 := createMultiSigPacket(packet.Hash, 1, packet.Owners, packet.Signatures)
+	if verifyPacketSignature(packet03) {
+		t.Error("approves packet whose threshold was changed after signing")
+	}
+
+	// a plain signature over the document hash is not a partial signature
+	plainSignature := signHash(packet.Hash, *keys[2])
+	packet04 := createMultiSigPacket(packet.Hash, 2, packet.Owners, [][]byte{packet.Signatures[0], {}, plainSignature})
+	if verifyPacketSignature(packet04) {
+		t.Error("approves packet with a signature over the wrong digest")
+	}
+
+	// an owner listed twice can not sign twice
+	owners := [][]byte{keys[0].Public, keys[0].Public}
+	signature := signMultiSig(packet.Hash, 2, owners, *keys[0])
+	packet05 := createMultiSigPacket(packet.Hash, 2, owners, [][]byte{signature, signature})
+	if verifyPacketSignature(packet05) {
+		t.Error("approves packet with a duplicated co-owner")
+	}
+
+	// threshold can not exceed the number of owners
+	packet06 := createMultiSigPacket(packet.Hash, 4, packet.Owners, packet.Signatures)
+	if verifyPacketSignature(packet06) {
+		t.Error("approves packet with an impossible threshold")
+	}
+}
+
+func TestFindMultiSigPacketByCoOwner(t *testing.T){
+	useDifficulty(t, 4294967295) // all hashes pass
+
+	packet, keys := createTestMultiSigPacket(2, 2)
+	g  := &genesisBlock
+	b1 := &Block{Index: g.Index + 1,
+				 Nonce: 5000,
+				 PrevHash: g.Hash,
+				 Data: []Packet{packet},
+				 Hash: []byte{}}
+	b1.Hash = b1.calcHashForBlock(5000)
+
+	if !g.isValidNextBlock(b1){
+		t.Error("fails to validate block with a multi-signature packet")
+	}
+
+	chain := Blockchain{Blocks: []Block{*g, *b1}}
+	for i, key := range keys {
+		found := chain.findPacketByHashAndPublicKey(packet.Hash, key.Public)
+		if !equalPackets(found, packet) {
+			t.Errorf("fails to find packet by co-owner %v", i)
+		}
+	}
+
+	// changing a signature must change the block hash
+	b2 := *b1
+	b2.Data = []Packet{createMultiSigPacket(packet.Hash, 2, packet.Owners, [][]byte{packet.Signatures[0], packet.Signatures[1], signMultiSig(packet.Hash, 2, packet.Owners, *keys[2])})}
+	if string(b2.calcHashForBlock(5000)) == string(b1.Hash) {
+		t.Error("block hash does not commit to the co-owner signatures")
+	}
+}
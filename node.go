@@ -1,6 +1,8 @@
 package main
 
 import(
+    "context"
+    "errors"
     "fmt"
     "net"
     "os"
@@ -8,174 +10,532 @@ import(
     "strings"
     "regexp"
     "net/http"
-    "io/ioutil"
+    "sync"
+    "time"
 )
 
+const shutdownTimeout = 5 * time.Second
+
+/*
+The node's state is owned by the select loop in run.  Other goroutines,
+such as the miner and the command line, must not touch the fields below
+directly; they read through the get* methods, which take the read lock,
+and mostly change state by sending on the node's channels.  A few changes
+that the loop has no part in are made directly under the write lock
+instead: setMasterKey, addKnownPeers, reindex and loadStores.  Nothing
+writes to a peer while holding the lock, as a peer that stopped reading
+would hold up every reader.
+*/
 type Node struct {
+    mu            sync.RWMutex // guards every field up to masterKey
     connections   map[net.Conn]int
     nextConnID    int
     blockchain    Blockchain
     curPacketList []Packet
     address       string
-    seed          string
+    id            string   // the ID peers know this node by, see identity.go
+    seed          string   // the seed node this node joined through
+    seeds         []string // seed nodes to try, in a random order
+    listenPort    string
+    public        bool
+    addressVotes  map[net.Conn]string // the IP each peer sees this node at
     seenBlocks    map[string]bool
+    curRecordList []Record
+    masterKey     *extendedKey // loaded by newseed or restore, used by derive
+    config        Config   // read only once the node is running
+    knownPeers    []string // addresses this node has dialed, redialed on the next run
+
+    ctx      context.Context    // cancelled when the node is shutting down
+    stop     context.CancelFunc // shuts the node down
+    transport Transport // tcp until run picks the one in the config
+    sealer    *Keypair  // the authority key blocks are sealed with under proof of authority, nil if not an authority
+    listener  net.Listener
+    apiServer *http.Server // nil unless api.address is set
+    metrics   *metricsRegistry
+    miners   sync.WaitGroup
+
+    packetChannel            chan Packet
+    recordChannel            chan Record // typed records waiting to be mined
+    blockWrapperChannel      chan *BlockWrapper
+    newConnChannel           chan net.Conn // new connections added
+    disconChannel            chan net.Conn // new disconnection
+    connRequestChannel       chan net.Conn // received a request to send connections
+    sentAddressesChannel     chan []string // received addresses to make connections
+    blockchainRequestChannel chan net.Conn
+    sentBlockchainChannel    chan Blockchain
+    versionChannel           chan versionMessage
+    sealChannel              chan bool // asks for a block to be sealed now, under --dev
 }
 
-func (myNode Node) run(listenPort string, seedData string, publicFlag bool) {
-    joinFlag := false
-    if seedData != "" { joinFlag = true } // join if user specifies a seed node 
-    
-    // specify ports to seed and listen to
-    myNode.updatePorts(listenPort, seedData, publicFlag)
+func (myNode *Node) run(ctx context.Context, listenPort string, seeds []string, publicFlag bool) {
+    ctx, myNode.stop = context.WithCancel(ctx)
+    myNode.ctx       = ctx
 
-    // create channel
-    packetChannel            := make(chan Packet)
-    blockWrapperChannel      := make(chan *BlockWrapper)
-    newConnChannel           := make(chan net.Conn) // new connections added
-    disconChannel            := make(chan net.Conn) // new disconnection
-    connRequestChannel       := make(chan net.Conn) // received a request to send connections 
-    sentAddressesChannel     := make(chan []string) // received addresses to make connections
-    blockchainRequestChannel := make(chan net.Conn)
-    sentBlockchainChannel    := make(chan Blockchain)
+    // pick how the node reaches its peers, and the identity it authenticates with
+    identity, err := loadTransportIdentity(myNode.config)
+    if err != nil {
+        p2pLog.Error("unable to load the identity key", "path", identityPath(myNode.config), "err", err)
+        return
+    }
+    transport, err := newTransport(myNode.config.Network, identity)
+    if err != nil {
+        p2pLog.Error("unable to set up the transport", "transport", myNode.config.Network.Transport, "err", err)
+        return
+    }
+    myNode.transport = transport
+    if tlsTransport, ok := transport.(tlsTransport); ok { // only peers over tls know each other by ID
+        myNode.mu.Lock()
+        myNode.id = tlsTransport.id
+        myNode.mu.Unlock()
+    }
+
+    // an authority seals blocks with its key instead of mining them, see poa.go
+    if myNode.config.Consensus.AuthorityKey != "" {
+        sealer, err := loadAuthorityKey(myNode.config.Consensus.AuthorityKey)
+        if err != nil {
+            minerLog.Error("unable to load the authority key", "path", myNode.config.Consensus.AuthorityKey, "err", err)
+            return
+        }
+        myNode.sealer = sealer
+    }
+
+    // specify the port to listen to and the seeds to join through
+    myNode.updatePorts(listenPort, seeds, publicFlag)
 
-    // set difficulty
-    difficulty = 200
+    // pick up where the node left off, if it keeps a data directory
+    myNode.loadStores()
 
     // listen to user input
-    go listenForUserInput(blockWrapperChannel, packetChannel, &myNode)
+    go listenForUserInput(myNode)
 
     // listen on network
-    listenForConnections(listenPort, newConnChannel)
-    if joinFlag { // if the user requested to join a seed node // need to make sure you can't join if you don't supply a seed
-        fmt.Println("Dialing seed node at port " + seedData + "...")
-         go dialNode(myNode.seed, newConnChannel)
+    myNode.listener = listenForConnections(myNode.transport, listenPort, myNode.newConnChannel)
+    if myNode.config.API.Address != "" {
+        server, err := myNode.startAPI(myNode.config.API.Address)
+        if err != nil {
+            apiLog.Error("unable to start the API", "address", myNode.config.API.Address, "err", err)
+        }
+        myNode.apiServer = server
+    }
+    if len(myNode.seeds) > 0 { // join if the user specified seed nodes
+        p2pLog.Info("dialing seed nodes", "seeds", myNode.seeds)
+        go myNode.joinSeeds()
+    }
+    for _, address := range myNode.getKnownPeers() { // redial the peers saved by the last run
+        if !containsAddress(myNode.seeds, address) && address != myNode.address {
+            go dialNode(myNode.transport, address, myNode.newConnChannel)
+        }
     }
 
     myNode.printNode()
+    myNode.serve(ctx)
+}
 
-    // go routines
+// handles the node's connections and messages until ctx is cancelled, then
+// shuts the node down; run sets the node up first, while tests wire nodes
+// together with in-memory connections and call serve directly
+func (myNode *Node) serve(ctx context.Context) {
     for {
         select {
-            case conn         := <- newConnChannel: // listener picked up new conn
-                myNode.nextConnID = myNode.nextConnID + 1
-                myNode.connections[conn] = myNode.nextConnID // assign connection an ID
-                go listenToConn(conn, blockWrapperChannel, packetChannel, disconChannel, connRequestChannel, sentAddressesChannel, blockchainRequestChannel, sentBlockchainChannel)
+            case conn         := <- myNode.newConnChannel: // listener picked up new conn
+                sendVersion(conn) // before anything else can write to the connection
+                myNode.addConnection(conn)
+                go myNode.listenToConn(conn)
 
-            case discon       := <- disconChannel: // established connection disconnected
-                connID := myNode.connections[discon]
-                delete(myNode.connections, discon) // remove the connection from the nodes list of connections
-                fmt.Printf("* Connection %v has been disconnected \n", connID)
+            case discon       := <- myNode.disconChannel: // established connection disconnected
+                myNode.removeConnection(discon)
 
-            case packet       := <- packetChannel:
+            case packet       := <- myNode.packetChannel:
                 myNode.handlePacket(packet)
+                myNode.sealDevBlock(false)
+
+            case record       := <- myNode.recordChannel:
+                myNode.handleRecord(record)
+                myNode.sealDevBlock(false)
+
+            case <- myNode.sealChannel: // the user entered seal
+                myNode.sealDevBlock(true)
 
-            case blockWrapper := <- blockWrapperChannel:  // new blockWrapper sent to node // handles adding, validating, and sending blocks to network
+            case blockWrapper := <- myNode.blockWrapperChannel:  // new blockWrapper sent to node // handles adding, validating, and sending blocks to network
                 myNode.handleBlockWrapper(blockWrapper)
 
-            case conn         := <-  connRequestChannel:  // was requested addresses to send
+            case conn         := <-  myNode.connRequestChannel:  // was requested addresses to send
                 addressesToSendTo := myNode.getRemoteAddresses()
                 sendConnectionsToNode(conn, addressesToSendTo)
 
-            case addresses    := <- sentAddressesChannel:  //received addresses to add
-                fmt.Printf("Seed node sent these addresses to connect to:\n-> %v\n", addresses)
-                myNode.handleSentAddresses(addresses, newConnChannel)
+            case addresses    := <- myNode.sentAddressesChannel:  //received addresses to add
+                p2pLog.Info("seed node sent addresses to connect to", "addresses", addresses)
+                myNode.handleSentAddresses(addresses, myNode.newConnChannel)
+
+            case conn         := <- myNode.blockchainRequestChannel:
+                sendBlockchainToNode(conn, myNode.getBlockchain())
+
+            case blockchain   := <- myNode.sentBlockchainChannel: // node was sent a blockchain
+                myNode.handleSentBlockchain(blockchain, myNode.blockWrapperChannel)
 
-            case conn         := <- blockchainRequestChannel:
-                sendBlockchainToNode(conn, myNode.blockchain)
+            case message      := <- myNode.versionChannel: // a peer told us the address it sees us at
+                myNode.handleVersion(message)
 
-            case blockchain   := <- sentBlockchainChannel: // node was sent a blockchain
-                myNode.handleSentBlockchain(blockchain, blockWrapperChannel)
+            case <- ctx.Done(): // interrupted, terminated or the user entered quit
+                myNode.shutdown()
+                return
         }
 
     }
 }
 
-func (n *Node) updatePorts(listenPort string, seedData string, publicFlag bool) {
-    if publicFlag{
-        n.seed = seedData + ":1999" // if public ip, seed is specifiec seedData:1999
-        n.address = getPublicIP() + ":1999" // must set up port forwarding
+// stops the miners, saves the node's state, says goodbye to every peer and
+// closes the connections, giving up on anything still blocked after shutdownTimeout
+func (n *Node) shutdown() {
+    p2pLog.Info("shutting down")
+    deadline := time.Now().Add(shutdownTimeout)
+
+    if n.listener != nil {
+        n.listener.Close()
+    }
+    if n.apiServer != nil {
+        apiCtx, cancel := context.WithDeadline(context.Background(), deadline)
+        stopAPI(apiCtx, n.apiServer)
+        cancel()
+    }
+
+    minersStopped := make(chan bool)
+    go func() {
+        n.miners.Wait()
+        close(minersStopped)
+    }()
+    select {
+        case <- minersStopped:
+        case <- time.After(time.Until(deadline)):
+            minerLog.Warn("gave up waiting for the miner to stop")
+    }
+
+    n.flushStores()
+
+    n.mu.RLock()
+    connections := n.copyConnections()
+    n.mu.RUnlock()
+    for conn := range connections {
+        conn.SetDeadline(deadline) // a peer that stopped reading can't hold up the shutdown
+        writeCommunication(conn, Communication{ID: 7})
+        conn.Close()
+    }
+    p2pLog.Info("node stopped")
+}
+
+// loads the blockchain and known peers saved in the data directory
+func (n *Node) loadStores() {
+    n.mu.Lock()
+    defer n.mu.Unlock()
+    if n.config.Storage.DataDir == "" {
+        return
+    }
+
+    blockchain, err := loadBlockchain(n.config.Storage.DataDir)
+    if err != nil {
+        chainLog.Error("unable to load the saved blockchain", "err", err)
+    } else if len(blockchain.Blocks) > 0 && string(blockchain.Blocks[0].Hash) == string(genesisBlock.Hash) && (len(blockchain.Blocks) == 1 || blockchain.isValidChain()) {
+        n.blockchain = blockchain
+        n.seenBlocks = map[string]bool{}
+        for _, b := range blockchain.Blocks {
+            n.seenBlocks[string(b.Hash)] = true
+        }
+        chainLog.Info("loaded the saved blockchain", "length", len(blockchain.Blocks), "datadir", n.config.Storage.DataDir)
+    } else if len(blockchain.Blocks) > 0 {
+        chainLog.Warn("the saved blockchain is invalid, starting from the genesis block", "err", blockchain.checkChain())
+    }
+
+    peers, err := loadPeers(n.config.Storage.DataDir)
+    if err != nil {
+        p2pLog.Error("unable to load the saved peers", "err", err)
+    }
+    n.knownPeers = peers
+}
+
+// writes the blockchain and known peers to the data directory
+func (n *Node) flushStores() {
+    n.mu.RLock()
+    defer n.mu.RUnlock()
+    if n.config.Storage.DataDir == "" {
+        return
+    }
+    if err := saveBlockchain(n.config.Storage.DataDir, n.blockchain); err != nil {
+        chainLog.Error("unable to save the blockchain", "err", err)
+    }
+    if err := savePeers(n.config.Storage.DataDir, n.knownPeers); err != nil {
+        p2pLog.Error("unable to save the peers", "err", err)
+    }
+    chainLog.Info("saved the blockchain and peers", "length", len(n.blockchain.Blocks), "peers", len(n.knownPeers), "datadir", n.config.Storage.DataDir)
+}
+
+func (n *Node) addConnection(conn net.Conn) {
+    n.mu.Lock()
+    defer n.mu.Unlock()
+    n.nextConnID = n.nextConnID + 1
+    n.connections[conn] = n.nextConnID // assign connection an ID
+}
+
+func (n *Node) removeConnection(conn net.Conn) {
+    n.mu.Lock()
+    defer n.mu.Unlock()
+    connID := n.connections[conn]
+    delete(n.connections, conn) // remove the connection from the nodes list of connections
+    connWriters.Delete(conn)
+    delete(n.addressVotes, conn)
+    p2pLog.Info("connection disconnected", "conn", connID, "remote", conn.RemoteAddr().String())
+}
+
+func (n *Node) updatePorts(listenPort string, seeds []string, publicFlag bool) {
+    resolvedSeeds := resolveSeeds(n.seedResolvers(seeds), publicFlag, n.config.Network.DefaultPort, n.transport.localHost())
+
+    n.mu.Lock()
+    defer n.mu.Unlock()
+    n.seeds      = resolvedSeeds
+    n.listenPort = listenPort
+    n.public     = publicFlag
+    if n.config.Network.ExternalAddr != "" {
+        n.address = n.config.Network.ExternalAddr // advertised as given, never voted on
     } else { 
-        n.seed = getPrivateIP() + ":" + seedData  // no default seed
-        n.address = getPrivateIP() + listenPort
+        n.address = n.transport.localHost() + listenPort // a public node replaces this once its peers agree where they see it
+    }
+}
+
+// counts the address a peer sees this node at, and moves a public node to the address most peers agree on
+func (n *Node) handleVersion(message versionMessage) {
+    n.mu.Lock()
+    defer n.mu.Unlock()
+    if net.ParseIP(message.version.ObservedAddress) == nil {
+        return
+    }
+    if _, connected := n.connections[message.conn]; !connected { // the peer disconnected since
+        return
+    }
+    n.addressVotes[message.conn] = message.version.ObservedAddress
+
+    if !n.public || n.config.Network.ExternalAddr != "" {
+        return
+    }
+    if ip, found := majorityAddress(n.addressVotes); found {
+        address := net.JoinHostPort(ip, strings.TrimPrefix(n.listenPort, ":"))
+        if address != n.address {
+            n.address = address
+            p2pLog.Info("peers agree on this node's public address", "address", address)
+        }
     }
 }
 
+// the seeds given to run, then those of the seeds file and DNS name in the config
+func (n *Node) seedResolvers(seeds []string) []SeedResolver {
+    resolvers := []SeedResolver{staticSeedResolver(seeds)}
+    if n.config.Network.SeedsFile != "" {
+        resolvers = append(resolvers, fileSeedResolver{path: n.config.Network.SeedsFile})
+    }
+    if n.config.Network.DNSSeed != "" {
+        resolvers = append(resolvers, newDNSSeedResolver(n.config.Network.DNSSeed))
+    }
+    return resolvers
+}
+
+// joins the network through the first seed that answers, which becomes the node's seed
+func (n *Node) joinSeeds() {
+    conn, address, joined := dialSeeds(n.getSeeds(), n.transport.dial)
+    if !joined {
+        p2pLog.Warn("none of the seed nodes answered, the node is not connected to the network")
+        return
+    }
+    p2pLog.Info("connected to seed", "local", conn.LocalAddr().String(), "seed", address, "peer", peerID(conn))
+
+    n.mu.Lock()
+    n.seed       = address
+    n.knownPeers = addUniqueAddresses(n.knownPeers, []string{address})
+    n.mu.Unlock()
+
+    select {
+        case n.newConnChannel <- conn:
+        case <- n.ctx.Done():
+            conn.Close()
+    }
+}
+
+// a copy of the connections, to write to once n.mu is released so a slow or
+// stalled peer does not hold up everything reading the node; call with n.mu held
+func (n *Node) copyConnections() map[net.Conn]int {
+    connections := make(map[net.Conn]int, len(n.connections))
+    for conn, id := range n.connections {
+        connections[conn] = id
+    }
+    return connections
+}
+
 func (n *Node) forwardBlockWrapperToNetwork(blockWrapper BlockWrapper, connections map[net.Conn]int) {
     for conn, _ := range connections { // loop through all this nodes connections
         // destinationAddr := conn.RemoteAddr().String() // get the destination of the connection
-        communication := Communication{0, blockWrapper, []string{}, Blockchain{}, Packet{}}
-        encoder       := gob.NewEncoder(conn)
-        encoder.Encode(communication)        
+        communication := Communication{ID: 0, BlockWrapper: blockWrapper}
+        writeCommunication(conn, communication)
     }
 }
 
 func (n *Node) forwardPacketToNetwork(packet Packet, connections map[net.Conn]int) {
     for conn, _ := range connections { // loop through all this nodes connections
-        communication := Communication{0, BlockWrapper{}, []string{}, Blockchain{}, packet}
-        encoder       := gob.NewEncoder(conn)
-        encoder.Encode(communication)        
+        communication := Communication{ID: 5, Packet: packet}
+        writeCommunication(conn, communication)
     }
 }
 
 func (n *Node) handlePacket(packet Packet){
-    fmt.Println("received new packet!")
-    if verifyPacketSignature(packet){
+    if peers, added := n.addPacket(packet); added {
+        n.forwardPacketToNetwork(packet, peers)
+    }
+}
+
+// adds a valid packet to the list waiting to be mined, returning the peers to forward it to
+func (n *Node) addPacket(packet Packet) (map[net.Conn]int, bool) {
+    n.mu.Lock()
+    defer n.mu.Unlock()
+    mempoolLog.Debug("received packet", "hash", fmt.Sprintf("%x", packet.Hash))
+    if verifyPacket(packet){
         // check to see if its in the nodes current packet list
         if packetListHasPacket(n.curPacketList, packet){
-            fmt.Println("Packet is valid, but I already have it.")
+            mempoolLog.Debug("packet is valid but already waiting to be mined", "hash", fmt.Sprintf("%x", packet.Hash))
+        } else if n.blockchain.hasPacket(packet) {
+            mempoolLog.Debug("packet is valid but already mined", "hash", fmt.Sprintf("%x", packet.Hash))
         } else {
             // add to current list of packets to be mined into the block
             n.curPacketList = append(n.curPacketList, packet)
-            n.forwardPacketToNetwork(packet, n.connections)
+            return n.copyConnections(), true
+        }
+    } else {
+        mempoolLog.Warn("rejected packet, signature does not verify", "hash", fmt.Sprintf("%x", packet.Hash))
+    }
+    return nil, false
+}
+
+func (n *Node) forwardRecordToNetwork(record Record, connections map[net.Conn]int) {
+    for conn, _ := range connections {
+        communication := Communication{ID: 6, Record: record}
+        writeCommunication(conn, communication)
+    }
+}
+
+func (n *Node) handleRecord(record Record){
+    if peers, added := n.addRecord(record); added {
+        n.forwardRecordToNetwork(record, peers)
+    }
+}
+
+// adds a valid record to the list waiting to be mined, returning the peers to forward it to
+func (n *Node) addRecord(record Record) (map[net.Conn]int, bool) {
+    n.mu.Lock()
+    defer n.mu.Unlock()
+    mempoolLog.Debug("received record", "type", record.Type)
+    if verifyRecord(record, nil){
+        if recordListHasRecord(n.curRecordList, record){
+            mempoolLog.Debug("record is valid but already waiting to be mined", "type", record.Type)
+        } else if n.blockchain.hasRecord(record) {
+            mempoolLog.Debug("record is valid but already mined", "type", record.Type)
+        } else {
+            n.curRecordList = append(n.curRecordList, record)
+            return n.copyConnections(), true
         }
     } else {
-        fmt.Println("packet signature does not verify")
+        mempoolLog.Warn("rejected record, it does not verify", "type", record.Type)
+    }
+    return nil, false
+}
+
+// drops packets that were mined into a block from the list still waiting to be mined
+func (n *Node) removeMinedPackets(block Block){
+    remaining := []Packet{}
+    for _, packet := range n.curPacketList {
+        if !packetListHasPacket(blockPackets(block), packet) {
+            remaining = append(remaining, packet)
+        }
+    }
+    n.curPacketList = remaining
+}
+
+// drops records that were mined into a block from the list still waiting to be mined
+func (n *Node) removeMinedRecords(block Block){
+    remaining := []Record{}
+    for _, record := range n.curRecordList {
+        if !recordListHasRecord(block.Records, record) {
+            remaining = append(remaining, record)
+        }
     }
+    n.curRecordList = remaining
 }
 
 func (n *Node) handleBlockWrapper(blockWrapper *BlockWrapper){
+    added, peers, sender := n.addBlockWrapper(blockWrapper)
+    if added {
+        n.forwardBlockWrapperToNetwork(BlockWrapper{Block: blockWrapper.Block, Sender: n.getAddress()}, peers)
+    }
+    if sender != nil {
+        requestBlockchain(sender) //request blockchain ending in block, ba
+    }
+}
+
+// adds the block if it follows the blockchain, returning the peers to forward
+// it to, or the connection of its sender when the block is ahead of the chain
+func (n *Node) addBlockWrapper(blockWrapper *BlockWrapper) (bool, map[net.Conn]int, net.Conn) {
+    n.mu.Lock()
+    defer n.mu.Unlock()
     block  := blockWrapper.Block
     if blockWrapper.Sender != n.address{
-        fmt.Printf("Received block #%v from network\n", block.Index)
+        chainLog.Debug("received block from network", "index", block.Index, "sender", blockWrapper.Sender)
     } else {
-        fmt.Printf("Mined block #%v \n", block.Index)
+        chainLog.Debug("received mined block", "index", block.Index)
     }
     seenBlock := n.seenBlocks[string(block.Hash)] == true
     if !seenBlock {
         lastBlock := n.blockchain.getLastBlock()
-        blockValid := lastBlock.isValidNextBlock(&block)
+        blockValid := lastBlock.isValidNextBlock(&block) && n.blockchain.hasValidAuthority(&block)
         if blockValid {
             n.seenBlocks[string(block.Hash)] = true // only set to seen if we validate it, otherwise it will come around again
-            n.forwardBlockWrapperToNetwork(BlockWrapper{Block: block, Sender: n.address}, n.connections)
             n.blockchain.addBlock(block)
-            fmt.Printf("Block #%v is valid, adding to blockchain and forwarding to network\n", block.Index)
+            n.removeMinedPackets(block)
+            n.removeMinedRecords(block)
+            chainLog.Info("added block, forwarding to network", "index", block.Index, "hash", fmt.Sprintf("%x", block.Hash))
+            return true, n.copyConnections(), nil
         } else {
+            rejectedBlocks.add(1)
             if block.Index > lastBlock.Index { 
-                fmt.Printf("Received invalid block %v, requesting full blockchain...\n", block.Index)                        
-                requestBlockchain(n.getConnForAddress(blockWrapper.Sender)) //request blockchain ending in block, ba                                                        
+                chainLog.Info("block is ahead of the chain, requesting full blockchain", "index", block.Index, "sender", blockWrapper.Sender)
+                return false, nil, n.connForAddress(blockWrapper.Sender)
             }
         }
     } else {
-        fmt.Printf("Already seen block #%v before, ignoring..\n", block.Index)
+        chainLog.Debug("already seen block, ignoring", "index", block.Index)
     }
+    return false, nil, nil
 }
 
 func (n *Node) handleSentAddresses(addresses []string, newConnChannel chan net.Conn){
+    n.mu.Lock()
+    defer n.mu.Unlock()
     approvedAddresses := []string{}
     for i := range addresses {
         r, _ := regexp.Compile(":.*") // match everything after the colon
         port := r.FindString(addresses[i])
         if len(port) == 5 {  // in a real network this should simply be 1999
-            go dialNode(addresses[i], newConnChannel)
+            go dialNode(n.transport, addresses[i], newConnChannel)
             approvedAddresses = append(approvedAddresses, addresses[i])
         }
     }
-    fmt.Printf("These new connections will be added:\n->%v\n", approvedAddresses)
+    n.knownPeers = addUniqueAddresses(n.knownPeers, approvedAddresses)
+    p2pLog.Info("dialing addresses sent by seed", "addresses", approvedAddresses)
 }
 
 func (n *Node) handleSentBlockchain(blockchain Blockchain, blockWrapperChannel chan *BlockWrapper){
-    fmt.Println("You were sent a blockchain!")
-    if blockchain.isValidChain() {
+    n.mu.Lock()
+    defer n.mu.Unlock()
+    chainLog.Debug("received blockchain", "length", len(blockchain.Blocks))
+    valid := blockchain.isValidChain()
+    if valid && blockchain.totalWork() <= n.blockchain.totalWork() { // on a tie, keep the blockchain seen first
+        chainLog.Info("rejected blockchain, it has no more work than ours", "length", len(blockchain.Blocks), "work", blockchain.totalWork())
+        return
+    }
+    if valid {
         lastIndex := len(blockchain.Blocks)-1
         semiReplacementChain := Blockchain{blockchain.Blocks[:lastIndex]}
         n.blockchain = semiReplacementChain
@@ -186,17 +546,104 @@ func (n *Node) handleSentBlockchain(blockchain Blockchain, blockWrapperChannel c
         }
         n.seenBlocks = seenBlocks //replace with the associated seen blocks
 
-        fmt.Printf("Accepted blockchain of length %v \n", len(blockchain.Blocks))
+        chainLog.Info("accepted blockchain", "length", len(blockchain.Blocks))
         lastBlock := blockchain.Blocks[lastIndex]
         blockWrapper := BlockWrapper{Block: lastBlock, Sender: n.address}
         go func () {blockWrapperChannel <- &blockWrapper}()
-        fmt.Println("sent the tip of the replacement chain to the blockchannel")
+        chainLog.Debug("sent the tip of the replacement chain to be validated")
     } else {
-        fmt.Println("Blockchain rejected, invalid!")
+        chainLog.Warn("rejected blockchain, it is invalid", "length", len(blockchain.Blocks))
+    }
+}
+
+// returns a copy of the blockchain that is safe to read from any goroutine
+func (n *Node) getBlockchain() Blockchain {
+    n.mu.RLock()
+    defer n.mu.RUnlock()
+    return Blockchain{Blocks: n.blockchain.Blocks[:len(n.blockchain.Blocks):len(n.blockchain.Blocks)]}
+}
+
+// returns a copy of the packets waiting to be mined
+func (n *Node) getCurPacketList() []Packet {
+    n.mu.RLock()
+    defer n.mu.RUnlock()
+    return append([]Packet{}, n.curPacketList...)
+}
+
+// returns a copy of the records waiting to be mined
+func (n *Node) getCurRecordList() []Record {
+    n.mu.RLock()
+    defer n.mu.RUnlock()
+    return append([]Record{}, n.curRecordList...)
+}
+
+func (n *Node) getAddress() string {
+    n.mu.RLock()
+    defer n.mu.RUnlock()
+    return n.address
+}
+
+func (n *Node) getSeed() string {
+    n.mu.RLock()
+    defer n.mu.RUnlock()
+    return n.seed
+}
+
+func (n *Node) getSeeds() []string {
+    n.mu.RLock()
+    defer n.mu.RUnlock()
+    return append([]string{}, n.seeds...)
+}
+
+func (n *Node) getMasterKey() *extendedKey {
+    n.mu.RLock()
+    defer n.mu.RUnlock()
+    return n.masterKey
+}
+
+func (n *Node) setMasterKey(masterKey *extendedKey) {
+    n.mu.Lock()
+    defer n.mu.Unlock()
+    n.masterKey = masterKey
+}
+
+func (n *Node) getKnownPeers() []string {
+    n.mu.RLock()
+    defer n.mu.RUnlock()
+    return append([]string{}, n.knownPeers...)
+}
+
+func (n *Node) addKnownPeers(addresses []string) {
+    n.mu.Lock()
+    defer n.mu.Unlock()
+    n.knownPeers = addUniqueAddresses(n.knownPeers, addresses)
+}
+
+func addUniqueAddresses(known []string, addresses []string) []string {
+    for _, address := range addresses {
+        if !containsAddress(known, address) {
+            known = append(known, address)
+        }
+    }
+    return known
+}
+
+func containsAddress(addresses []string, address string) bool {
+    for _, knownAddress := range addresses {
+        if knownAddress == address {
+            return true
+        }
     }
+    return false
+}
+
+func (n *Node) getConnForAddress(address string) (net.Conn){
+    n.mu.RLock()
+    defer n.mu.RUnlock()
+    return n.connForAddress(address)
 }
 
-func (n Node) getConnForAddress(address string) (net.Conn){
+func (n *Node) connForAddress(address string) (net.Conn){
     for conn := range n.connections {
         remoteAddr := conn.RemoteAddr().String()
         if address == remoteAddr {
@@ -207,7 +654,9 @@ func (n Node) getConnForAddress(address string) (net.Conn){
     return emptyConn
 }
 
-func (n Node) getRemoteAddresses() (remoteAddresses []string) {
+func (n *Node) getRemoteAddresses() (remoteAddresses []string) {
+    n.mu.RLock()
+    defer n.mu.RUnlock()
     for conn, _ := range n.connections {
         remoteAddr := conn.RemoteAddr().String()
         remoteAddresses = append(remoteAddresses, remoteAddr)
@@ -216,7 +665,7 @@ func (n Node) getRemoteAddresses() (remoteAddresses []string) {
 }
 
 // this could be made more efficient by not using getRemoteAddresses()
-func (n Node) hasConnectionOfAddress(address string) (bool) {
+func (n *Node) hasConnectionOfAddress(address string) (bool) {
     remoteAddresses := n.getRemoteAddresses()
     for i := 0; i < len(remoteAddresses); i++ {
         if address == remoteAddresses[i] {
@@ -226,17 +675,26 @@ func (n Node) hasConnectionOfAddress(address string) (bool) {
     return false
 }
 
-func (n Node) printConnections(){
+func (n *Node) printConnections(){
     for conn, id := range n.connections {
         localAddr := conn.LocalAddr().String()
         remoteAddr := conn.RemoteAddr().String()
-        fmt.Printf(" ID: %v, Connection: %v to %v \n", id, localAddr, remoteAddr)
+        fmt.Printf(" ID: %v, Connection: %v to %v %v\n", id, localAddr, remoteAddr, peerID(conn))
     }
 }
 
-func (n Node) printNode(){
+func (n *Node) printNode(){
+    if outputFormat == "json" {
+        fmt.Print(formatJSON(n.getNodeView()))
+        return
+    }
+    n.mu.RLock()
+    defer n.mu.RUnlock()
     fmt.Println("*------------------*\nYour Node:\n Connections:")
-    fmt.Printf(" Your Address:\n  %v \n Seed Address:\n  %v\n", n.address, n.seed)
+    if n.id != "" {
+        fmt.Printf(" Your ID:\n  %v\n", n.id)
+    }
+    fmt.Printf(" Your Address:\n  %v \n Seed Address:\n  %v\n Seed Nodes:\n  %v\n", n.address, n.seed, n.seeds)
     n.printConnections()
     fmt.Println(" Seen Blocks:")
     printSeenBlockWrapper(n.seenBlocks)
@@ -245,112 +703,176 @@ func (n Node) printNode(){
     fmt.Println("*------------------*")
 }
 
-func newNode() Node {
-    myNode := Node{connections:   make(map[net.Conn]int),
+func newNode() *Node {
+    myNode := &Node{connections:   make(map[net.Conn]int),
                    nextConnID:    0,
                    blockchain:    Blockchain{[]Block{genesisBlock}},
                    curPacketList: []Packet{},
                    address:       "",
                    seed:          "",
-                   seenBlocks:    map[string]bool{}}
+                   seenBlocks:    map[string]bool{},
+                   curRecordList: []Record{},
+                   masterKey:     nil,
+                   knownPeers:    []string{},
+                   seeds:         []string{},
+                   addressVotes:  map[net.Conn]string{},
+                   config:        defaultConfig(),
+                   transport:     tcpTransport{},
+                   ctx:           context.Background(),
+                   packetChannel:            make(chan Packet),
+                   recordChannel:            make(chan Record),
+                   blockWrapperChannel:      make(chan *BlockWrapper),
+                   newConnChannel:           make(chan net.Conn),
+                   disconChannel:            make(chan net.Conn),
+                   connRequestChannel:       make(chan net.Conn),
+                   sentAddressesChannel:     make(chan []string),
+                   blockchainRequestChannel: make(chan net.Conn),
+                   sentBlockchainChannel:    make(chan Blockchain),
+                   versionChannel:           make(chan versionMessage),
+                   sealChannel:              make(chan bool)}
+    myNode.metrics = newNodeMetrics(myNode)
     return myNode
 }
 
-func listenForConnections(port string, newConnChannel chan net.Conn) {
-    listener, err := net.Listen("tcp", port)
+func listenForConnections(transport Transport, port string, newConnChannel chan net.Conn) net.Listener {
+    listener, err := transport.listen(port)
     if err != nil {
-        fmt.Println("There was an error setting up the listener:")
-        fmt.Println(err)
+        p2pLog.Error("unable to set up the listener", "port", port, "err", err)
+        return nil
     }
 
     go acceptConn(listener, newConnChannel)
+    return listener
 }
 
 func acceptConn(listener net.Listener, newConnChannel chan net.Conn) {
     for {
         conn, err := listener.Accept()
+        if errors.Is(err, net.ErrClosed) { // the node is shutting down
+            return
+        }
         if err != nil {
-            fmt.Printf("There was an error accepting a new connection: \n %v", err)
+            p2pLog.Error("unable to accept a connection", "err", err)
+            continue
         }
-        fmt.Println("* Listener accepted connection through port " + conn.LocalAddr().String() + " from " + conn.RemoteAddr().String())
-        newConnChannel <- conn //send to conection channel
+        go func() { // a slow handshake must not hold up the next connection
+            if err := handshake(conn); err != nil {
+                p2pLog.Warn("peer failed to authenticate", "remote", conn.RemoteAddr().String(), "err", err)
+                conn.Close()
+                return
+            }
+            p2pLog.Info("accepted connection", "local", conn.LocalAddr().String(), "remote", conn.RemoteAddr().String(), "peer", peerID(conn))
+            newConnChannel <- conn //send to conection channel
+        }()
     }
 }
 
-func dialNode(address string, newConnChannel chan net.Conn) {
-    conn, err := net.Dial("tcp", address)
+func dialNode(transport Transport, address string, newConnChannel chan net.Conn) {
+    conn, err := transport.dial(address)
     if err != nil {
-        fmt.Println("**Make sure there is someone listening at " + address + "**")
-        fmt.Println(err)
+        p2pLog.Warn("unable to dial, make sure someone is listening", "address", address, "err", err)
+        return
     }
-    fmt.Println("Connection established out of port " + conn.LocalAddr().String() + " dialing to " + conn.RemoteAddr().String())
+    p2pLog.Info("connected", "local", conn.LocalAddr().String(), "remote", conn.RemoteAddr().String(), "peer", peerID(conn))
     newConnChannel <- conn
 }
 
-func listenToConn(          conn                          net.Conn, 
-                            blockWrapperChannel      chan *BlockWrapper,
-                            packetChannel            chan Packet,
-                            disconChannel            chan net.Conn,
-                            connRequestChannel       chan net.Conn,
-                            sentAddressesChannel     chan []string,
-                            blockchainRequestChannel chan net.Conn,
-                            sentBlockchainChannel    chan Blockchain) {
+func (n *Node) listenToConn(conn net.Conn) {
+    // one decoder for the whole connection, as gob reads ahead of the
+    // communication it decodes, and the types are only sent once
+    decoder := gob.NewDecoder(countingConn{Conn: conn, sent: &bytesSent, received: &bytesReceived})
+    greeted := false
     for {
-        decoder := gob.NewDecoder(conn)
         var communication Communication
         err := decoder.Decode(&communication)
 
         if err != nil {
-            fmt.Println(err)
+            p2pLog.Debug("connection closed", "remote", conn.RemoteAddr().String(), "err", err)
             break
         }
+        if !greeted && !isCompatibleVersion(communication) { // see version.go
+            p2pLog.Warn("peer speaks another protocol version, disconnecting", "remote", conn.RemoteAddr().String(), "version", communication.Version.Protocol, "ours", protocolVersion)
+            conn.Close()
+            break
+        }
+        greeted = true
         switch communication.ID {
         case 0:
-            blockWrapperChannel <- &communication.BlockWrapper
+            n.blockWrapperChannel <- &communication.BlockWrapper
         case 1:
-            sentAddressesChannel <- communication.SentAddresses
+            n.sentAddressesChannel <- communication.SentAddresses
         case 2:
-            fmt.Println("You have been requested to send your connection addresses to a peer at " + conn.RemoteAddr().String() + " ...")
-            connRequestChannel <- conn
+            p2pLog.Debug("peer requested connection addresses", "remote", conn.RemoteAddr().String())
+            n.connRequestChannel <- conn
         case 3:
-            sentBlockchainChannel <- communication.Blockchain
+            n.sentBlockchainChannel <- communication.Blockchain
         case 4:
-            fmt.Println("You have been requested to send your blockchain address to a peer at " + conn.RemoteAddr().String() + " ...")
-            blockchainRequestChannel <- conn
+            p2pLog.Debug("peer requested blockchain", "remote", conn.RemoteAddr().String())
+            n.blockchainRequestChannel <- conn
         case 5:
-            fmt.Println("You have been sent a packet!")
-            packetChannel <- communication.Packet
+            n.packetChannel <- communication.Packet
+        case 6:
+            n.recordChannel <- communication.Record
+        case 7:
+            p2pLog.Info("peer is shutting down", "remote", conn.RemoteAddr().String())
+            conn.Close() // the next decode fails and ends the loop
+        case 8:
+            n.versionChannel <- versionMessage{conn: conn, version: communication.Version}
         default:
-            fmt.Println("There was a problem decoding the message")
+            p2pLog.Warn("received a message of unknown type", "remote", conn.RemoteAddr().String(), "id", communication.ID)
             break
         }
     }
-    disconChannel <- conn // disconnect must have occurred if we exit the for loop
+    select {
+        case n.disconChannel <- conn: // disconnect must have occurred if we exit the for loop
+        case <- n.ctx.Done(): // nobody is left to remove the connection
+    }
+}
+
+// the encoder of a connection, which the peer's single decoder reads from
+// in listenToConn.  Connections are written to by the node's loop, the miner
+// and the command line, so each write of a whole communication holds the lock.
+type connWriter struct {
+    mu      sync.Mutex
+    encoder *gob.Encoder
+}
+
+var connWriters sync.Map
+
+func writeCommunication(conn net.Conn, communication Communication) error {
+    if conn == nil {
+        return errors.New("no connection to send to")
+    }
+    value, _ := connWriters.LoadOrStore(conn, &connWriter{})
+    writer   := value.(*connWriter)
+    writer.mu.Lock()
+    defer writer.mu.Unlock()
+
+    if writer.encoder == nil {
+        writer.encoder = gob.NewEncoder(countingConn{Conn: conn, sent: &bytesSent, received: &bytesReceived})
+    }
+    return writer.encoder.Encode(communication)
 }
 
 func requestConnections(conn net.Conn){
-    communication := Communication{2, BlockWrapper{}, []string{}, Blockchain{}, Packet{}}
-    encoder       := gob.NewEncoder(conn)
-    encoder.Encode(communication)
+    communication := Communication{ID: 2}
+    writeCommunication(conn, communication)
 }
 
 func requestBlockchain(conn net.Conn){
-    communication := Communication{4, BlockWrapper{}, []string{}, Blockchain{}, Packet{}}
-    encoder   := gob.NewEncoder(conn)
-    encoder.Encode(communication)
+    communication := Communication{ID: 4}
+    writeCommunication(conn, communication)
 }
 
 func sendConnectionsToNode(conn net.Conn, addresses []string){
-    communication := Communication{1, BlockWrapper{}, addresses, Blockchain{}, Packet{}}
-    encoder       := gob.NewEncoder(conn)
-    encoder.Encode(communication)
+    communication := Communication{ID: 1, SentAddresses: addresses}
+    writeCommunication(conn, communication)
 }
 
 func sendBlockchainToNode(conn net.Conn, blockchain Blockchain){
-    communication := Communication{3, BlockWrapper{}, []string{}, blockchain, Packet{}}
-    encoder   := gob.NewEncoder(conn)
-    encoder.Encode(communication)
-    fmt.Printf("Sent my copy of blockchain to %v", conn.RemoteAddr().String())
+    communication := Communication{ID: 3, Blockchain: blockchain}
+    writeCommunication(conn, communication)
+    p2pLog.Debug("sent blockchain", "remote", conn.RemoteAddr().String(), "length", len(blockchain.Blocks))
 }
 
 func sendBlockWrapperFromMinedBlock(block Block, blockWrapperChannel chan *BlockWrapper){
@@ -370,22 +892,3 @@ func getPrivateIP() string {
 
     return address[0]
 }
-
-func getPublicIP() string {
-    resp, err := http.Get("http://myexternalip.com/raw")
-    if err != nil {
-        os.Stderr.WriteString(err.Error())
-        os.Stderr.WriteString("\n")
-        os.Exit(1)
-    }
-    defer resp.Body.Close()
-
-    myPublicIP, err := ioutil.ReadAll(resp.Body)
-    if err != nil {
-        fmt.Println(err)
-    }
-
-    myPublicIPstring := strings.Trim(string(myPublicIP), "\n")
-    return myPublicIPstring
-}
-
@@ -1,11 +1,14 @@
 package main 
 
 import (
+	"bytes"
 	"testing"
 	"net"
 	"fmt"
 	"time"
-	// "encoding/gob"
+	"sync"
+	"context"
+	"encoding/gob"
 )
 
 func createTestListener(port string) *net.Listener {
@@ -56,7 +59,7 @@ func TestDialNode(t *testing.T){
         fmt.Println("There was an error setting up the listener:")
         fmt.Println(err)
     }
-	go dialNode("127.0.0.1:1999", newConnChannel)
+	go dialNode(tcpTransport{}, "127.0.0.1:1999", newConnChannel)
 	acceptedConn, err := listener.Accept()
 	if err != nil {
 		t.Error("Unable to make a connection using n.dialNode()")
@@ -74,7 +77,7 @@ func TestListenForConnections(t *testing.T){
 	listenPort       := ":2000" //specific 
 	newConnChannel   := make(chan net.Conn)
 
-	listenForConnections(listenPort, newConnChannel)
+	listenForConnections(tcpTransport{}, listenPort, newConnChannel)
 	conn, err := net.Dial("tcp", "127.0.0.1" + listenPort)
 	if err != nil {
 		t.Error("Unable to make a connection using listenForUserInput()")
@@ -83,131 +86,328 @@ func TestListenForConnections(t *testing.T){
 	conn.SetDeadline(time.Now())
 }
 
-// func TestListenToConn(t *testing.T){
-// 	listenPort := ":1999"
-
-// 	// create channels
-//     blockWrapperChannel      := make(chan *BlockWrapper)
-//     disconChannel            := make(chan net.Conn) // new disconnestion
-//     connRequestChannel       := make(chan net.Conn) // received a request to send connections 
-//     sentAddressesChannel     := make(chan []string) // received addresses to make connections
-//     blockchainRequestChannel := make(chan net.Conn)
-//     sentBlockchainChannel    := make(chan Blockchain)
-
-//     listener, err := net.Listen("tcp", listenPort)
-//     if err != nil {
-//         fmt.Println("There was an error setting up the listener:")
-//         fmt.Println(err)
-//     }
-
-//     connOut, err := net.Dial("tcp", "127.0.0.1" + listenPort)
-// 	if err != nil {
-// 		fmt.Println(err)
-// 	}
-//     connIn, err := listener.Accept()
-
-//     go listenToConn(connIn,
-//     			   blockWrapperChannel,
-//     			   disconChannel,
-//     			   connRequestChannel,
-//     			   sentAddressesChannel,
-//     			   blockchainRequestChannel,
-//     			   sentBlockchainChannel)
-
-//     comm0 := newComm(0)
-//     comm1 := newComm(1)
-//     comm2 := newComm(2)
-//     comm3 := newComm(3)
-//     comm4 := newComm(4)
-
-//     encoder := gob.NewEncoder(connIn)
-//     err = encoder.Encode(&comm0)
-//     if err != nil {
-//         fmt.Println(err)
-//         t.Error("Error receiving blockWrapper")
-//     }
-// 	fmt.Println("CHABA")
-
-//     blockWrapper := <- blockWrapperChannel
-//     fmt.Println(blockWrapper)
-//     if blockWrapper.Sender != comm0.BlockWrapper.Sender {
-//     	t.Error("blockWrapper.Sender != comm0.BlockWrapper.Sender")
-//     }
-
-//     err = encoder.Encode(&comm1)
-//     if err != nil {
-//         fmt.Println(err)
-//         t.Error("Error receiving sent addresses")
-//     }
-
-//     err = encoder.Encode(&comm2)
-//     if err != nil {
-//         fmt.Println(err)
-//         t.Error("Error sending connections")
-//     }
-//     err = encoder.Encode(&comm3)
-//     if err != nil {
-//         fmt.Println(err)
-//         t.Error("Error receiving blockchain")
-//     }
-//     err = encoder.Encode(&comm4)
-//     if err != nil {
-//         fmt.Println(err)
-//         t.Error("Error sending blockchain")
-//     }
-//     err = encoder.Encode("disconnect")
-//     if err != nil {
-//         fmt.Println(err)
-//     }
-//     discon := <- disconChannel
-//     if discon.LocalAddr().String() != discon.RemoteAddr().String() {
-//     	t.Error("disconnection and connection do not align")
-//     }
-// 	listener.Close()
-// 	connIn.Close()
-// 	connOut.Close()
-// }
-
-// func TestForwardBlockWrapperToNewtork(t *testing.T){
-// 	n := newNode()
-// 	listenPort       := ":1999"
-// 	newConnChannel   := make(chan net.Conn)
-// 	blockWrapper := emptyBlockWrapper()
-
-// 	listener, err := net.Listen("tcp", listenPort)
-//     if err != nil {
-//         fmt.Println("There was an error setting up the listener:")
-//         fmt.Println(err)
-//     }
-	
-// 	go dialNode("127.0.0.1:1999", newConnChannel)
-// 	conn1, err := listener.Accept()
-// 	go dialNode("127.0.0.1:1999", newConnChannel)
-// 	conn2, err := listener.Accept()
-
-// 	connections := map[net.Conn]int {conn1:0, conn2:1}
-// 	n.forwardBlockWrapperToNetwork(blockWrapper, connections)
-
-//  //    var comm Communication
-// 	// decoder := gob.NewDecoder(conn1)
-// 	// err = decoder.Decode(&comm)
-// 	// if err != nil {
-// 	// 	fmt.Println(err)
-// 	// }
-	
-// 	// fmt.Print(comm.BlockWrapper.Sender)
-// 	conn1.Close()
-// 	conn2.Close()
-// 	listener.Close()
-// }
+func TestListenToConn(t *testing.T){
+	n := newNode()
+	connIn, connOut := net.Pipe()
+	go n.listenToConn(connIn)
+
+	// every communication is written before any is read, and reaches the
+	// node in one read, as back to back writes to a TCP connection may
+	stream := &bytes.Buffer{}
+	sender := writerConn{stream}
+	sendVersion(sender)
+	writeCommunication(sender, Communication{ID: 0, BlockWrapper: BlockWrapper{Sender: "10.0.0.2:1999"}})
+	writeCommunication(sender, Communication{ID: 1, SentAddresses: []string{"10.0.0.3:1999"}})
+	requestConnections(sender)
+	sendBlockchainToNode(sender, Blockchain{[]Block{genesisBlock}})
+	requestBlockchain(sender)
+	for i := 0; i < 20; i++ {
+		writeCommunication(sender, Communication{ID: 5, Packet: Packet{Memo: fmt.Sprint(i)}})
+	}
+	go connOut.Write(stream.Bytes())
+
+	lost := time.After(5 * time.Second)
+	select {
+	case <- n.versionChannel:
+	case <- lost:
+		t.Fatal("version written back to back was not received")
+	}
+	select {
+	case blockWrapper := <- n.blockWrapperChannel:
+		if blockWrapper.Sender != "10.0.0.2:1999" {
+			t.Error("blockWrapper.Sender was not received")
+		}
+	case <- lost:
+		t.Fatal("block wrapper written back to back was not received")
+	}
+	select {
+	case addresses := <- n.sentAddressesChannel:
+		if len(addresses) != 1 || addresses[0] != "10.0.0.3:1999" {
+			t.Error("sent addresses were not received")
+		}
+	case <- lost:
+		t.Fatal("sent addresses written back to back were not received")
+	}
+	select {
+	case conn := <- n.connRequestChannel:
+		if conn != connIn {
+			t.Error("request for connections did not come from the connection")
+		}
+	case <- lost:
+		t.Fatal("request for connections written back to back was not received")
+	}
+	select {
+	case blockchain := <- n.sentBlockchainChannel:
+		if len(blockchain.Blocks) != 1 {
+			t.Error("blockchain was not received")
+		}
+	case <- lost:
+		t.Fatal("blockchain written back to back was not received")
+	}
+	select {
+	case conn := <- n.blockchainRequestChannel:
+		if conn != connIn {
+			t.Error("request for the blockchain did not come from the connection")
+		}
+	case <- lost:
+		t.Fatal("request for the blockchain written back to back was not received")
+	}
+	for i := 0; i < 20; i++ {
+		select {
+		case packet := <- n.packetChannel:
+			if packet.Memo != fmt.Sprint(i) {
+				t.Errorf("packet %v arrived as packet %v", packet.Memo, i)
+			}
+		case <- lost:
+			t.Fatalf("only %v of 20 packets written back to back were received", i)
+		}
+	}
+
+	connOut.Close()
+	if discon := <- n.disconChannel; discon != connIn {
+		t.Error("disconnection and connection do not align")
+	}
+}
+
+func TestForwardBlockWrapperToNewtork(t *testing.T){
+	n := newNode()
+	conn1, peer1 := net.Pipe()
+	conn2, peer2 := net.Pipe()
+	blockWrapper := BlockWrapper{Block: genesisBlock, Sender: "10.0.0.1:1999"}
+
+	received := make(chan BlockWrapper)
+	for _, peer := range []net.Conn{peer1, peer2} {
+		go func(peer net.Conn) {
+			var communication Communication
+			gob.NewDecoder(peer).Decode(&communication)
+			received <- communication.BlockWrapper
+		}(peer)
+	}
+
+	connections := map[net.Conn]int {conn1:0, conn2:1}
+	n.forwardBlockWrapperToNetwork(blockWrapper, connections)
+	for i := 0; i < 2; i++ {
+		if forwarded := <- received; string(forwarded.Block.Hash) != string(genesisBlock.Hash) {
+			t.Error("block was not forwarded to every connection")
+		}
+	}
+	conn1.Close()
+	conn2.Close()
+}
+
+// a peer that never reads must not hold up readers of the node while a packet is forwarded to it
+func TestStalledPeerDoesNotBlockNode(t *testing.T){
+	n := newNode()
+	conn, stalled := net.Pipe() // writes to conn block until stalled reads, which it never does
+	defer stalled.Close()
+	defer conn.Close()
+	n.connections[conn] = 0
+
+	keys   := GenerateNewKeypair()
+	hash   := hashDocument([]byte("stalled"))
+	packet := Packet{Hash: hash, Owner: keys.Public, Signature: signHash(hash, *keys)}
+	go n.handlePacket(packet)
+
+	read := make(chan int)
+	go func() {
+		waitFor(time.Second, func() bool { return len(n.getCurPacketList()) == 1 })
+		read <- len(n.getBlockchain().Blocks)
+	}()
+	select {
+	case <-read:
+	case <-time.After(2 * time.Second):
+		t.Error("Node was held up by a peer that does not read")
+	}
+}
+
+func TestStalledPeerDoesNotBlockShutdown(t *testing.T){
+	n := newNode()
+	conn, stalled := net.Pipe() // the goodbye to conn blocks until the shutdown deadline
+	defer stalled.Close()
+	defer conn.Close()
+	n.connections[conn] = 0
+
+	go n.shutdown()
+	time.Sleep(100 * time.Millisecond) // until shutdown is writing the goodbye
+
+	read := make(chan int)
+	go func() { read <- len(n.getBlockchain().Blocks) }()
+	select {
+	case <-read:
+	case <-time.After(time.Second):
+		t.Error("Node was held up while saying goodbye to a peer that does not read")
+	}
+}
 
+// polls until the condition holds or the timeout passes
+func waitFor(timeout time.Duration, condition func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if condition() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return condition()
+}
 
+// runs three nodes joined through the first and checks a packet and a block
+// reach all of them; run with -race to check the node's state is guarded
+func TestNodesConverge(t *testing.T){
+	useDifficulty(t, 4294967295) // all hashes pass
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n1, stopped1 := startTestNode(t, ctx, ":2101", "")
+	n2, stopped2 := startTestNode(t, ctx, ":2102", "2101")
+	n3, stopped3 := startTestNode(t, ctx, ":2103", "2101")
+	if !waitFor(5*time.Second, func() bool { return len(n1.getRemoteAddresses()) == 2 }) {
+		t.Fatal("nodes did not connect to the seed")
+	}
 
+	// read the nodes' state from other goroutines while they handle traffic
+	stop := make(chan bool)
+	readers := sync.WaitGroup{}
+	for _, n := range []*Node{n1, n2, n3} {
+		readers.Add(1)
+		go func(n *Node) {
+			defer readers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					n.getBlockchain().getLastBlock()
+					n.getCurPacketList()
+					n.getCurRecordList()
+					n.getRemoteAddresses()
+				}
+			}
+		}(n)
+	}
 
+	keys         := GenerateNewKeypair()
+	documentHash := hashDocument([]byte("converge"))
+	packet       := Packet{Hash: documentHash, Owner: keys.Public, Signature: signHash(documentHash, *keys)}
+	n2.packetChannel <- packet
+	if !waitFor(5*time.Second, func() bool { return len(n3.getCurPacketList()) == 1 && len(n1.getCurPacketList()) == 1 }) {
+		t.Error("packet was not forwarded to every node")
+	}
 
+	block := Block{Index:    1,
+	               PrevHash: genesisBlock.Hash,
+	               Data:     []Packet{packet},
+	               Records:  []Record{}}
+	block.Hash = block.calcHashForBlock(0)
+	n2.blockWrapperChannel <- &BlockWrapper{Block: block, Sender: n2.getAddress()}
+
+	converged := waitFor(5*time.Second, func() bool {
+		for _, n := range []*Node{n1, n2, n3} {
+			if string(n.getBlockchain().getLastBlock().Hash) != string(block.Hash) {
+				return false
+			}
+		}
+		return true
+	})
+	if !converged {
+		t.Error("nodes did not converge on the new block")
+	}
 
+	close(stop)
+	readers.Wait()
+	cancel()
+	<-stopped1
+	<-stopped2
+	<-stopped3
+}
 
+// runs a node until ctx is cancelled, the returned channel is closed once it has shut down
+func startTestNode(t *testing.T, ctx context.Context, listenPort string, seedPort string) (*Node, chan bool) {
+	n       := newNode()
+	stopped := make(chan bool)
+	go func() {
+		seeds := []string{}
+		if seedPort != "" {
+			seeds = append(seeds, seedPort)
+		}
+		n.run(ctx, listenPort, seeds, false)
+		close(stopped)
+	}()
+	if !waitFor(2*time.Second, func() bool { return n.getAddress() != "" }) {
+		t.Fatal("node did not start")
+	}
+	return n, stopped
+}
 
+func TestNodeShutdown(t *testing.T){
+	useDifficulty(t, 0) // no hash passes, so the miner is still busy when the node stops
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	seed, seedStopped := startTestNode(t, ctx, ":2104", "")
+
+	n       := newNode()
+	n.config.Storage.DataDir = t.TempDir()
+	stopped := make(chan bool)
+	go func() {
+		n.run(context.Background(), ":2105", []string{"2104"}, false)
+		close(stopped)
+	}()
+	if !waitFor(5*time.Second, func() bool { return len(seed.getRemoteAddresses()) == 1 }) {
+		t.Fatal("node did not connect to the seed")
+	}
+	startMining(n)
 
+	n.stop() // what quit does
+	select {
+	case <-stopped:
+	case <-time.After(shutdownTimeout + time.Second):
+		t.Fatal("node did not shut down within the deadline")
+	}
 
+	if !waitFor(2*time.Second, func() bool { return len(seed.getRemoteAddresses()) == 0 }) {
+		t.Error("seed did not drop the connection of the node that said goodbye")
+	}
 
+	blockchain, err := loadBlockchain(n.config.Storage.DataDir)
+	if err != nil || len(blockchain.Blocks) != 1 {
+		t.Error("blockchain was not saved on shutdown")
+	}
+	peers, err := loadPeers(n.config.Storage.DataDir)
+	if err != nil || len(peers) != 1 || peers[0] != n.getSeed() {
+		t.Error("peers were not saved on shutdown")
+	}
+
+	cancel()
+	<-seedStopped
+}
+
+func TestAddUniqueAddresses(t *testing.T) {
+	known := addUniqueAddresses([]string{"a:1"}, []string{"b:2", "a:1", "b:2"})
+	if len(known) != 2 || known[1] != "b:2" {
+		t.Error("addUniqueAddresses should only add addresses not yet known")
+	}
+}
+
+func TestPublicNodeLearnsAddressFromPeers(t *testing.T){
+	ctx, cancel := context.WithCancel(context.Background())
+	seed, seedStopped := startTestNode(t, ctx, ":2106", "")
+
+	public  := newNode()
+	stopped := make(chan bool)
+	go func() {
+		public.run(ctx, ":2107", []string{"127.0.0.1:2106"}, true)
+		close(stopped)
+	}()
+	if !waitFor(5*time.Second, func() bool { return public.getAddress() == "127.0.0.1:2107" }) {
+		t.Error("public node did not take the address its peer sees it at, got " + public.getAddress())
+	}
+
+	// the seed is not public, so it keeps its own address
+	if seed.getAddress() != getPrivateIP()+":2106" {
+		t.Error("private node should not change its address")
+	}
+
+	cancel()
+	<-stopped
+	<-seedStopped
+}
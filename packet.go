@@ -2,8 +2,8 @@ package main
 
 import(
 	"crypto/sha256"
-	"io/ioutil"
 	"fmt"
+	"os"
 
 	// "github.com/nvonpentz/go-hashable-keys"
 )
@@ -12,14 +12,18 @@ type Packet struct {
 	Hash      []byte
 	Signature []byte
 	Owner     []byte
-}
 
-func readDocument(filePath string) []byte{
-	document, err := ioutil.ReadFile(filePath)
-	if err != nil {
-		fmt.Println(err)
-	}
-	return document
+	// multi-signature packets leave Owner and Signature empty and instead
+	// list every co-owner, with Signatures[i] empty if Owners[i] did not sign
+	Threshold  uint32
+	Owners     [][]byte
+	Signatures [][]byte
+
+	// optional metadata, covered by the signature when set (see metadata.go)
+	HashAlgorithm string // sha256 when empty
+	ContentType   string
+	Size          uint64
+	Memo          string
 }
 
 func hashDocument(document []byte) []byte{
@@ -38,37 +42,68 @@ func signHash(hash []byte, keys Keypair) []byte{
 }
 
 func createPacket(filepath string, keys Keypair) Packet {
-	document := readDocument(filepath)
-	documentHash := hashDocument(document)
+	documentHash, _, err := hashFile(filepath, defaultHashAlgorithm, os.Stdout) // streams, so documents may be larger than memory
+	if err != nil {
+		fmt.Println(err)
+	}
 	signature := signHash(documentHash, keys)
 
 	return Packet{Hash: documentHash, Signature: signature, Owner: keys.Public}
 }
 
 func verifyPacketSignature(packet Packet) bool {
-	return SignatureVerify(packet.Owner, packet.Signature, packet.Hash)
+	if isMultiSigPacket(packet) {
+		return verifyMultiSigPacket(packet)
+	}
+	return SignatureVerify(packet.Owner, packet.Signature, packetSigningHash(packet))
+}
+
+// a packet is valid if its metadata is within limits and its signatures verify
+func verifyPacket(packet Packet) bool {
+	return verifyPacketMetadata(packet) && verifyPacketSignature(packet)
 }
 
 func verifyPacketList(packets []Packet) bool {
 	for _ , packet := range packets{
-		if verifyPacketSignature(packet) == false {
+		if verifyPacket(packet) == false {
 			return false
 		}
 	}
 	return true
 }
 
-func hashPacketList(list []Packet) []byte {
+func hashPacket(packet Packet) []byte {
 	h := sha256.New()
-	for _ , packet :=range list {
-		h.Write(packet.Hash)
-		h.Write(packet.Signature)
-		h.Write(packet.Owner)
+	h.Write(packet.Hash)
+	h.Write(packet.Signature)
+	h.Write(packet.Owner)
+	if hasPacketMetadata(packet) {
+		h.Write(packetSigningHash(packet))
+	}
+	if isMultiSigPacket(packet) {
+		h.Write(multiSigDigest(packet.Hash, packet.Threshold, packet.Owners))
+		for _, signature := range packet.Signatures {
+			h.Write(lengthPrefixed(signature))
+		}
 	}
 
 	return h.Sum(nil)
 }
 
+// the packets are hashed into a merkle tree, so a single packet can be
+// proven to be in a block without the rest of its packets (see proof.go)
+func hashPacketList(list []Packet) []byte {
+	return merkleRoot(packetLeaves(list))
+}
+
+func packetLeaves(list []Packet) [][]byte {
+	leaves := [][]byte{}
+	for _ , packet := range list {
+		leaves = append(leaves, merkleLeafHash(hashPacket(packet)))
+	}
+	return leaves
+}
+
 func packetListHasPacket(packetList []Packet, packetInQuestion Packet) bool {
 	for _ , packet := range packetList {
 		if equalPackets(packet, packetInQuestion){
@@ -81,7 +116,7 @@ func packetListHasPacket(packetList []Packet, packetInQuestion Packet) bool {
 
 func packetListHasPacketHashAndPublicKey(packetList []Packet, packetHash, publicKey []byte) bool {
 	for _ , packet := range packetList {
-		if string(packet.Hash) == string(packetHash) && string(packet.Owner) == string(publicKey) {
+		if string(packet.Hash) == string(packetHash) && packetHasOwner(packet, publicKey) {
 			return true
 		}
 	}
@@ -91,7 +126,7 @@ func packetListHasPacketHashAndPublicKey(packetList []Packet, packetHash, public
 
 func getPacketFromListByHashAndPublicKey(packetList []Packet, packetHash, publicKey []byte) Packet {
 	for _ , packet := range packetList {
-		if string(packet.Hash) == string(packetHash) && string(packet.Owner) == string(publicKey){
+		if string(packet.Hash) == string(packetHash) && packetHasOwner(packet, publicKey){
 			return packet
 		}
 	}
@@ -103,8 +138,13 @@ func equalPackets(packet1, packet2 Packet) bool {
 	ownerEqual := string(packet1.Owner)     == string(packet2.Owner)
 	hashEqual  := string(packet1.Hash)      == string(packet2.Hash)
 	sigEqual   := string(packet1.Signature) == string(packet1.Signature)
+	ownersEqual := !isMultiSigPacket(packet1) && !isMultiSigPacket(packet2) ||
+				   string(multiSigDigest(packet1.Hash, packet1.Threshold, packet1.Owners)) ==
+				   string(multiSigDigest(packet2.Hash, packet2.Threshold, packet2.Owners))
+
+	metadataEqual := string(packetSigningHash(packet1)) == string(packetSigningHash(packet2))
 
-	return ownerEqual && hashEqual && sigEqual
+	return ownerEqual && hashEqual && sigEqual && ownersEqual && metadataEqual
 }
 
 
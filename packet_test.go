@@ -1,6 +1,7 @@
 package main 
 
 import(
+	"os"
 	"testing"
 )
 
@@ -16,7 +17,7 @@ func TestVerifyPacketSignature(t *testing.T){
 	}
 
 	// invalid packet; wrong public key for signature
-	doc       := readDocument("document.txt")
+	doc, _    := os.ReadFile("document.txt")
 	hashedDoc := hashDocument(doc)
 	signature := signHash(hashedDoc, *keys01) // sign with keys01
 
@@ -49,7 +50,7 @@ func TestVerifyPacketList(t *testing.T){
 	}
 
 	// create an invalid packet
-	doc       := readDocument("document.txt")
+	doc, _    := os.ReadFile("document.txt")
 	hashedDoc := hashDocument(doc)
 	signature := signHash(hashedDoc, *keys01) // sign with keys01
 
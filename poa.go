@@ -0,0 +1,397 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+/*
+poa.go is proof of authority, the ConsensusEngine for permissioned
+networks picked with consensus.engine = "poa".  Instead of mining, a set of
+authority keys take turns sealing blocks: the block at index i is sealed
+by authorities[i % len(authorities)], which signs the block hash.  The
+signer is part of the hash, so another authority can not sign the same
+block as its own.  verifyHeader checks the signature in place of the
+difficulty, and the blockchain checks the signer is an authority.
+
+So that an authority going offline does not stop the network, any other
+authority may seal in its place once it is late, the next one along
+first (see sealDelay).  A block sealed out of turn weighs less than one
+sealed in turn, so when the chains of two authorities fork, the one
+sealed in turn wins.  An authority may not seal any of the
+len(authorities)/2 blocks after one it sealed, so no authority can seal
+a run of blocks on its own: more than half of the authorities must be
+online for the chain to grow.
+
+The first authorities are listed in consensus.authorities, and every
+node on the network must list the same ones.  After that, authorities are
+added and removed by authority records on chain, which more than half of
+the current authorities must sign.  A record takes effect from the block
+after the one holding it, and names the number of authority records
+before it, its epoch, so it can not be replayed once the set has moved on.
+*/
+
+const authorityRecord uint8 = 5
+
+const (
+	authorityAdd    uint8 = 1
+	authorityRemove uint8 = 2
+)
+
+// set from consensus.authorities in the config
+var genesisAuthorities = [][]byte{}
+
+const sealPollInterval = 100 * time.Millisecond
+
+// the work of a block sealed by the authority in turn, and by another authority
+const (
+	inTurnWork    = 2
+	outOfTurnWork = 1
+)
+
+type AuthorityChange struct {
+	Kind       uint8
+	Key        []byte
+	Epoch      uint32 // authority records already on chain
+	Signers    [][]byte
+	Signatures [][]byte
+}
+
+func authorityChangeDigest(change AuthorityChange) []byte {
+	h := sha256.New()
+
+	epochBytes := make([]byte, 4)
+	binary.LittleEndian.PutUint32(epochBytes, change.Epoch)
+
+	h.Write([]byte{change.Kind})
+	h.Write(lengthPrefixed(change.Key))
+	h.Write(epochBytes)
+
+	return h.Sum(nil)
+}
+
+// adds one authority's signature to the change
+func signAuthorityChange(change AuthorityChange, keys Keypair) AuthorityChange {
+	change.Signers    = append(change.Signers, keys.Public)
+	change.Signatures = append(change.Signatures, signHash(authorityChangeDigest(change), keys))
+	return change
+}
+
+// checks the change on its own; whether its signers may make it depends on the chain, see applyAuthorityChange
+func verifyAuthorityChange(change AuthorityChange) bool {
+	if change.Kind != authorityAdd && change.Kind != authorityRemove {
+		return false
+	}
+	if len(change.Key) == 0 || len(change.Signers) == 0 || len(change.Signers) != len(change.Signatures) {
+		return false
+	}
+	digest := authorityChangeDigest(change)
+	for i, signer := range change.Signers {
+		if len(signer) == 0 || !SignatureVerify(signer, change.Signatures[i], digest) {
+			return false
+		}
+	}
+	return true
+}
+
+func init() {
+	registerRecordType(authorityRecord, recordType{
+		name: "authority",
+		validate: func(record Record, block *Block) bool {
+			var change AuthorityChange
+			return decodeRecord(record, &change) == nil && verifyAuthorityChange(change)
+		},
+		hash: func(record Record) []byte {
+			var change AuthorityChange
+			decodeRecord(record, &change)
+			h := sha256.New()
+			h.Write(authorityChangeDigest(change))
+			for i := range change.Signers {
+				h.Write(lengthPrefixed(change.Signers[i]))
+				h.Write(lengthPrefixed(change.Signatures[i]))
+			}
+			return h.Sum(nil)
+		},
+		describe: func(record Record) string {
+			var change AuthorityChange
+			decodeRecord(record, &change)
+			if change.Kind == authorityAdd {
+				return fmt.Sprintf("authority %v added", string(change.Key))
+			}
+			return fmt.Sprintf("authority %v removed", string(change.Key))
+		},
+	})
+}
+
+// the authorities and epoch after a change, or false if the current authorities did not approve it
+func applyAuthorityChange(authorities [][]byte, epoch uint32, change AuthorityChange) ([][]byte, uint32, bool) {
+	if change.Epoch != epoch {
+		return authorities, epoch, false
+	}
+	approvals := map[string]bool{}
+	for _, signer := range change.Signers {
+		if !hasAuthority(authorities, signer) || approvals[string(signer)] {
+			return authorities, epoch, false // a signer counts once, and only while an authority
+		}
+		approvals[string(signer)] = true
+	}
+	if len(approvals)*2 <= len(authorities) {
+		return authorities, epoch, false
+	}
+
+	changed := [][]byte{}
+	switch change.Kind {
+	case authorityAdd:
+		if hasAuthority(authorities, change.Key) {
+			return authorities, epoch, false
+		}
+		changed = append(append(changed, authorities...), change.Key)
+	case authorityRemove:
+		for _, authority := range authorities {
+			if string(authority) != string(change.Key) {
+				changed = append(changed, authority)
+			}
+		}
+		if len(changed) == len(authorities) || len(changed) == 0 { // not an authority, or the last one
+			return authorities, epoch, false
+		}
+	}
+	return changed, epoch + 1, true
+}
+
+func hasAuthority(authorities [][]byte, key []byte) bool {
+	for _, authority := range authorities {
+		if string(authority) == string(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// the authorities and epoch after the given blocks, and whether every authority record in them was approved
+func authoritiesAfter(blocks []Block) ([][]byte, uint32, bool) {
+	authorities := genesisAuthorities
+	epoch       := uint32(0)
+	for _, block := range blocks {
+		var approved bool
+		authorities, epoch, approved = applyBlockAuthorityChanges(authorities, epoch, block)
+		if !approved {
+			return authorities, epoch, false
+		}
+	}
+	return authorities, epoch, true
+}
+
+func applyBlockAuthorityChanges(authorities [][]byte, epoch uint32, block Block) ([][]byte, uint32, bool) {
+	for _, record := range block.Records {
+		var change AuthorityChange
+		if record.Type != authorityRecord || decodeRecord(record, &change) != nil {
+			continue
+		}
+		var approved bool
+		authorities, epoch, approved = applyAuthorityChange(authorities, epoch, change)
+		if !approved {
+			return authorities, epoch, false
+		}
+	}
+	return authorities, epoch, true
+}
+
+// the authority whose turn it is to seal the block at index
+func authorityInTurn(authorities [][]byte, index uint32) []byte {
+	if len(authorities) == 0 {
+		return nil
+	}
+	return authorities[int(index)%len(authorities)]
+}
+
+// the position of key among the authorities, or -1 if it is not one
+func authorityPosition(authorities [][]byte, key []byte) int {
+	for i, authority := range authorities {
+		if string(authority) == string(key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// whether signer may seal the block after blocks: it must be one of the
+// authorities and not have sealed any of the last len(authorities)/2 blocks
+func mayAuthoritySeal(blocks []Block, authorities [][]byte, signer []byte) bool {
+	if authorityPosition(authorities, signer) < 0 {
+		return false
+	}
+	for i := len(blocks) - 1; i >= 0 && i >= len(blocks)-len(authorities)/2; i-- {
+		if string(blocks[i].Signer) == string(signer) {
+			return false
+		}
+	}
+	return true
+}
+
+// how long after the last block signer waits to seal the block at index: one
+// period when it is in turn, and a period more for each authority it comes
+// after the one in turn, so the next one along takes over from an authority
+// that is offline; false when it may not seal the block at all
+func sealDelay(blocks []Block, authorities [][]byte, index uint32, signer []byte, period time.Duration) (time.Duration, bool) {
+	if !mayAuthoritySeal(blocks, authorities, signer) {
+		return 0, false
+	}
+	behind := (authorityPosition(authorities, signer) - int(index)%len(authorities) + len(authorities)) % len(authorities)
+	return period * time.Duration(1+behind), true
+}
+
+// whether the block carries a valid signature by the key it names
+func isSignedBlock(block *Block) bool {
+	return len(block.Signer) != 0 && len(block.Signature) != 0 && SignatureVerify(block.Signer, block.Signature, block.Hash)
+}
+
+// whether block, following the blockchain, was sealed by an authority that
+// may seal it and holds only approved authority changes; always true under other engines
+func (blockchain Blockchain) hasValidAuthority(block *Block) bool {
+	if _, poa := consensus.(poaEngine); !poa {
+		return true
+	}
+	authorities, epoch, _ := authoritiesAfter(blockchain.Blocks)
+	if !mayAuthoritySeal(blockchain.Blocks, authorities, block.Signer) {
+		return false
+	}
+	_, _, approved := applyBlockAuthorityChanges(authorities, epoch, *block)
+	return approved
+}
+
+// hasValidAuthority for every block of the blockchain, in one pass
+func (blockchain Blockchain) hasValidAuthorities() bool {
+	if _, poa := consensus.(poaEngine); !poa {
+		return true
+	}
+	authorities := genesisAuthorities
+	epoch       := uint32(0)
+	for i, block := range blockchain.Blocks {
+		if block.Index == 0 {
+			continue
+		}
+		if !mayAuthoritySeal(blockchain.Blocks[:i], authorities, block.Signer) {
+			return false
+		}
+		var approved bool
+		authorities, epoch, approved = applyBlockAuthorityChanges(authorities, epoch, block)
+		if !approved {
+			return false
+		}
+	}
+	return true
+}
+
+type poaEngine struct{}
+
+// names the node's authority key as the signer, and leaves out authority
+// changes that would make the block invalid
+func (poaEngine) prepare(n *Node, blockchain Blockchain, block *Block) {
+	authorities, epoch, _ := authoritiesAfter(blockchain.Blocks)
+	block.Records = sealableRecords(authorities, epoch, block.Records)
+	if n.sealer != nil {
+		block.Signer = n.sealer.Public
+	}
+}
+
+// seals the next block once its sealDelay has passed since the last block
+// arrived, a period when it is this node's turn, until the node shuts down
+func (poaEngine) seal(n *Node) (Block, bool) {
+	if n.sealer == nil {
+		minerLog.Error("only authorities seal blocks, set consensus.authority_key to this node's authority key")
+		return Block{}, false
+	}
+	period   := time.Duration(n.config.Consensus.Period) * time.Second
+	lastHash := n.getBlockchain().getLastBlock().Hash
+	tipSince := time.Now()
+	for {
+		blockchain := n.getBlockchain()
+		block      := nextBlock(n, blockchain)
+		if string(block.PrevHash) != string(lastHash) {
+			lastHash, tipSince = block.PrevHash, time.Now()
+		}
+		authorities, _, _ := authoritiesAfter(blockchain.Blocks)
+		delay, may := sealDelay(blockchain.Blocks, authorities, block.Index, n.sealer.Public, period)
+		if may && time.Since(tipSince) >= delay {
+			block.Hash      = block.calcHashForBlock(block.Nonce)
+			block.Signature = signHash(block.Hash, *n.sealer)
+			return block, true
+		}
+
+		select {
+		case <-time.After(sealPollInterval):
+		case <-n.ctx.Done():
+			return Block{}, false
+		}
+	}
+}
+
+// a block is sealed by a valid signature of the key it names
+func (poaEngine) verifyHeader(block *Block) bool {
+	return isSignedBlock(block)
+}
+
+// a block sealed in turn weighs more than one sealed out of turn
+func (poaEngine) work(blockchain Blockchain, block *Block) uint64 {
+	if block.Index == 0 {
+		return outOfTurnWork
+	}
+	authorities, _, _ := authoritiesAfter(blockchain.Blocks)
+	if string(block.Signer) == string(authorityInTurn(authorities, block.Index)) {
+		return inTurnWork
+	}
+	return outOfTurnWork
+}
+
+// the records that can go in the next block, leaving out authority changes
+// that are no longer approved, such as one whose epoch has passed, which
+// would make the whole block invalid
+func sealableRecords(authorities [][]byte, epoch uint32, records []Record) []Record {
+	sealable := []Record{}
+	for _, record := range records {
+		var change AuthorityChange
+		if record.Type == authorityRecord && decodeRecord(record, &change) == nil {
+			var approved bool
+			if authorities, epoch, approved = applyAuthorityChange(authorities, epoch, change); !approved {
+				continue
+			}
+		}
+		sealable = append(sealable, record)
+	}
+	return sealable
+}
+
+// reads the key an authority seals blocks with, a JSON file holding the
+// base58 keys genkeys prints: {"public": "...", "private": "..."}
+func loadAuthorityKey(path string) (*Keypair, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var key struct {
+		Public  string `json:"public"`
+		Private string `json:"private"`
+	}
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, err
+	}
+	if key.Public == "" || key.Private == "" {
+		return nil, fmt.Errorf("%v must hold a public and a private key", path)
+	}
+	return &Keypair{Public: []byte(strings.TrimSpace(key.Public)), Private: []byte(strings.TrimSpace(key.Private))}, nil
+}
+
+// the authorities listed in the config, as keys
+func parseAuthorities(authorities []string) [][]byte {
+	keys := [][]byte{}
+	for _, authority := range authorities {
+		keys = append(keys, []byte(strings.TrimSpace(authority)))
+	}
+	return keys
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// switches the package to proof of authority for one test, with the given
+// first authorities; no hash is below the difficulty, so blocks are only valid by their seal
+func usePoA(t *testing.T, authorities ...*Keypair) {
+	previousDifficulty := difficulty
+	consensus           = poaEngine{}
+	genesisAuthorities  = [][]byte{}
+	difficulty          = 0
+	for _, authority := range authorities {
+		genesisAuthorities = append(genesisAuthorities, authority.Public)
+	}
+	t.Cleanup(func() {
+		consensus          = powEngine{}
+		genesisAuthorities = [][]byte{}
+		difficulty         = previousDifficulty
+	})
+}
+
+// the block after lastBlock, sealed by keys
+func sealBlock(lastBlock Block, keys *Keypair, records []Record) Block {
+	block := Block{Index:    lastBlock.Index + 1,
+	               PrevHash: lastBlock.Hash,
+	               Data:     []Packet{},
+	               Records:  records,
+	               Signer:   keys.Public}
+	block.Hash      = block.calcHashForBlock(0)
+	block.Signature = signHash(block.Hash, *keys)
+	return block
+}
+
+// whether addBlock took the block
+func addedBlock(blockchain *Blockchain, block Block) bool {
+	length := len(blockchain.Blocks)
+	blockchain.addBlock(block)
+	return len(blockchain.Blocks) > length
+}
+
+func TestAuthoritiesTakeTurns(t *testing.T) {
+	a, b := GenerateNewKeypair(), GenerateNewKeypair()
+	authorities := [][]byte{a.Public, b.Public}
+	if string(authorityInTurn(authorities, 1)) != string(b.Public) || string(authorityInTurn(authorities, 2)) != string(a.Public) {
+		t.Error("Authorities do not seal in turn")
+	}
+	if authorityInTurn([][]byte{}, 1) != nil {
+		t.Error("An authority is in turn when there are none")
+	}
+}
+
+func TestSealedBlockValidity(t *testing.T) {
+	a, b := GenerateNewKeypair(), GenerateNewKeypair()
+	usePoA(t, a, b)
+
+	blockchain := Blockchain{[]Block{genesisBlock}}
+	block := sealBlock(genesisBlock, b, []Record{})
+	if !genesisBlock.isValidNextBlock(&block) || !blockchain.hasValidAuthority(&block) {
+		t.Error("Block sealed by the authority in turn is not valid")
+	}
+	if !addedBlock(&blockchain, block) {
+		t.Error("Sealed block was not added")
+	}
+
+	again := sealBlock(block, b, []Record{})
+	if blockchain.hasValidAuthority(&again) || addedBlock(&blockchain, again) {
+		t.Error("Block sealed by the authority that sealed the block before it is valid")
+	}
+
+	stranger := sealBlock(block, GenerateNewKeypair(), []Record{})
+	if addedBlock(&blockchain, stranger) {
+		t.Error("Block sealed by a key that is not an authority is valid")
+	}
+
+	forged := sealBlock(block, a, []Record{})
+	forged.Signature = signHash(forged.Hash, *b)
+	if block.isValidNextBlock(&forged) {
+		t.Error("Block signed by another key than its signer is valid")
+	}
+	unsigned := sealBlock(block, a, []Record{})
+	unsigned.Signature = nil
+	if block.isValidNextBlock(&unsigned) {
+		t.Error("Unsigned block is valid")
+	}
+
+	if !addedBlock(&blockchain, sealBlock(block, a, []Record{})) || !blockchain.isValidChain() {
+		t.Error("Chain sealed in turn is not valid")
+	}
+	blockchain.Blocks[2].Signer = b.Public
+	if blockchain.hasValidAuthorities() {
+		t.Error("Chain holding two blocks in a row sealed by one authority is valid")
+	}
+}
+
+func TestAuthorityChanges(t *testing.T) {
+	a, b, c := GenerateNewKeypair(), GenerateNewKeypair(), GenerateNewKeypair()
+	authorities := [][]byte{a.Public, b.Public, c.Public}
+	newcomer := GenerateNewKeypair()
+
+	change := AuthorityChange{Kind: authorityAdd, Key: newcomer.Public, Epoch: 0}
+	once := signAuthorityChange(change, *a)
+	if _, _, approved := applyAuthorityChange(authorities, 0, once); approved {
+		t.Error("Change signed by one of three authorities was approved")
+	}
+	if _, _, approved := applyAuthorityChange(authorities, 0, signAuthorityChange(once, *a)); approved {
+		t.Error("Change signed twice by the same authority was approved")
+	}
+	if _, _, approved := applyAuthorityChange(authorities, 0, signAuthorityChange(once, *newcomer)); approved {
+		t.Error("Change signed by a key that is not an authority was approved")
+	}
+
+	twice := signAuthorityChange(once, *b)
+	if !verifyAuthorityChange(twice) {
+		t.Error("Signed change is not valid")
+	}
+	added, epoch, approved := applyAuthorityChange(authorities, 0, twice)
+	if !approved || epoch != 1 || len(added) != 4 || !hasAuthority(added, newcomer.Public) {
+		t.Error("Change signed by two of three authorities was not applied")
+	}
+	if _, _, approved := applyAuthorityChange(added, epoch, twice); approved {
+		t.Error("Change was replayed after the authorities moved on")
+	}
+
+	tampered := twice
+	tampered.Key = GenerateNewKeypair().Public
+	if verifyAuthorityChange(tampered) {
+		t.Error("Change whose key was replaced is valid")
+	}
+
+	last := signAuthorityChange(AuthorityChange{Kind: authorityRemove, Key: a.Public, Epoch: 0}, *a)
+	if _, _, approved := applyAuthorityChange([][]byte{a.Public}, 0, last); approved {
+		t.Error("The last authority was removed")
+	}
+}
+
+func TestAuthorityChangesOnChain(t *testing.T) {
+	a, b := GenerateNewKeypair(), GenerateNewKeypair()
+	usePoA(t, a)
+
+	// a adds b, which seals its turns from the block after
+	change := signAuthorityChange(AuthorityChange{Kind: authorityAdd, Key: b.Public, Epoch: 0}, *a)
+	record := newRecord(authorityRecord, change)
+	blockchain := Blockchain{[]Block{genesisBlock}}
+	first := sealBlock(genesisBlock, a, []Record{record})
+	if !addedBlock(&blockchain, first) {
+		t.Fatal("Block holding an approved change was not added")
+	}
+	if authorities, epoch, _ := authoritiesAfter(blockchain.Blocks); len(authorities) != 2 || epoch != 1 {
+		t.Error("Change on chain did not add the authority")
+	}
+	if addedBlock(&blockchain, sealBlock(first, a, []Record{})) {
+		t.Error("Authority sealed two blocks in a row once there were two authorities")
+	}
+	if addedBlock(&blockchain, sealBlock(first, b, []Record{record})) {
+		t.Error("Block replaying a change was added")
+	}
+	second := sealBlock(first, b, []Record{})
+	if !addedBlock(&blockchain, second) || !addedBlock(&blockchain, sealBlock(second, a, []Record{})) {
+		t.Error("Authorities did not take turns after the change")
+	}
+
+	// the replayed change is left out of the next sealed block
+	if len(sealableRecords([][]byte{a.Public, b.Public}, 1, []Record{record})) != 0 {
+		t.Error("Change from a past epoch would be sealed")
+	}
+}
+
+func TestOutOfTurnSealing(t *testing.T) {
+	a, b, c := GenerateNewKeypair(), GenerateNewKeypair(), GenerateNewKeypair()
+	usePoA(t, a, b, c)
+
+	// b is in turn for block 1, c takes over if b is offline
+	blockchain := Blockchain{[]Block{genesisBlock}}
+	inTurn     := sealBlock(genesisBlock, b, []Record{})
+	outOfTurn  := sealBlock(genesisBlock, c, []Record{})
+	if !blockchain.hasValidAuthority(&outOfTurn) {
+		t.Error("Block sealed out of turn by an authority is not valid")
+	}
+	if consensus.work(blockchain, &outOfTurn) >= consensus.work(blockchain, &inTurn) {
+		t.Error("Block sealed out of turn weighs as much as one sealed in turn")
+	}
+	inTurnChain    := Blockchain{[]Block{genesisBlock, inTurn}}
+	outOfTurnChain := Blockchain{[]Block{genesisBlock, outOfTurn}}
+	if inTurnChain.totalWork() <= outOfTurnChain.totalWork() {
+		t.Error("Fork sealed in turn does not outweigh the fork sealed out of turn")
+	}
+
+	period := time.Second
+	if delay, may := sealDelay(blockchain.Blocks, genesisAuthorities, 1, b.Public, period); !may || delay != period {
+		t.Error("Authority in turn does not seal after one period:", delay)
+	}
+	if delay, may := sealDelay(blockchain.Blocks, genesisAuthorities, 1, c.Public, period); !may || delay != 2*period {
+		t.Error("Next authority along does not take over after two periods:", delay)
+	}
+	if delay, _ := sealDelay(blockchain.Blocks, genesisAuthorities, 1, a.Public, period); delay != 3*period {
+		t.Error("Last authority along does not wait the longest:", delay)
+	}
+
+	// c can not sign b's block as its own, as the signer is part of the block hash
+	resigned := inTurn
+	resigned.Signer    = c.Public
+	resigned.Signature = signHash(resigned.Hash, *c)
+	if genesisBlock.isValidNextBlock(&resigned) || addedBlock(&Blockchain{[]Block{genesisBlock}}, resigned) {
+		t.Error("Block signed again by another authority is valid")
+	}
+	if resealed := sealBlock(genesisBlock, c, []Record{}); string(resealed.Hash) == string(inTurn.Hash) {
+		t.Error("Blocks sealed by different authorities have the same hash")
+	}
+
+	// c may not seal block 2 as well, even in its turn, so a takes over
+	if _, may := sealDelay(outOfTurnChain.Blocks, genesisAuthorities, 2, c.Public, period); may {
+		t.Error("Authority may seal the block after its own")
+	}
+	if addedBlock(&outOfTurnChain, sealBlock(outOfTurn, c, []Record{})) {
+		t.Error("Authority sealed the block after its own")
+	}
+	if !addedBlock(&outOfTurnChain, sealBlock(outOfTurn, a, []Record{})) {
+		t.Error("Block sealed out of turn after another was not added")
+	}
+}
+
+func TestLoadAuthorityKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "authority.json")
+	keys := GenerateNewKeypair()
+	os.WriteFile(path, []byte(`{"public": "`+string(keys.Public)+`", "private": "`+string(keys.Private)+`"}`), 0600)
+	loaded, err := loadAuthorityKey(path)
+	if err != nil || string(loaded.Public) != string(keys.Public) || string(loaded.Private) != string(keys.Private) {
+		t.Error("Authority key was not loaded:", err)
+	}
+
+	os.WriteFile(path, []byte(`{"public": "`+string(keys.Public)+`"}`), 0600)
+	if _, err := loadAuthorityKey(path); err == nil {
+		t.Error("Loaded an authority key without its private key")
+	}
+}
+
+func TestSimulationSealsInTurn(t *testing.T) {
+	sim  := newSimNetwork(t, 3)
+	keys := []*Keypair{GenerateNewKeypair(), GenerateNewKeypair()}
+	usePoA(t, keys...)
+	t.Cleanup(func() { // stop the sealers before the consensus is switched back
+		sim.stop()
+		for _, n := range sim.nodes {
+			n.miners.Wait()
+		}
+	})
+	sim.connectAll()
+	sim.waitForPeers(2)
+
+	// the first two nodes are the authorities, the third only follows
+	for i, authority := range keys {
+		sim.nodes[i].sealer = authority
+		sim.nodes[i].config.Consensus.Period = 1
+		startMining(sim.nodes[i])
+	}
+
+	if !waitFor(10*time.Second, func() bool { return sim.nodes[2].getBlockchain().getLastBlock().Index >= 3 }) {
+		t.Fatal("authorities did not seal blocks: " + sim.tips())
+	}
+	blockchain := sim.nodes[2].getBlockchain()
+	for _, block := range blockchain.Blocks[1:4] {
+		if string(block.Signer) != string(keys[block.Index%2].Public) {
+			t.Error("Block was not sealed by the authority in turn")
+		}
+	}
+	if !blockchain.isValidChain() {
+		t.Error("Sealed chain is not valid")
+	}
+}
+
+func TestSimulationSealsPastOfflineAuthority(t *testing.T) {
+	sim  := newSimNetwork(t, 3)
+	keys := []*Keypair{GenerateNewKeypair(), GenerateNewKeypair(), GenerateNewKeypair()}
+	usePoA(t, keys...)
+	t.Cleanup(func() {
+		sim.stop()
+		for _, n := range sim.nodes {
+			n.miners.Wait()
+		}
+	})
+	sim.connectAll()
+	sim.waitForPeers(2)
+
+	// the third authority never seals, the other two seal its turns in its place
+	for i, authority := range keys[:2] {
+		sim.nodes[i].sealer = authority
+		sim.nodes[i].config.Consensus.Period = 1
+		startMining(sim.nodes[i])
+	}
+
+	if !waitFor(20*time.Second, func() bool { return sim.nodes[2].getBlockchain().getLastBlock().Index >= 3 }) {
+		t.Fatal("authorities did not seal past the offline one: " + sim.tips())
+	}
+	blockchain := sim.nodes[2].getBlockchain()
+	for _, block := range blockchain.Blocks[1:] {
+		if string(block.Signer) == string(keys[2].Public) {
+			t.Error("Block was sealed by the offline authority")
+		}
+	}
+	if !blockchain.isValidChain() {
+		t.Error("Chain sealed past the offline authority is not valid")
+	}
+}
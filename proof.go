@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+)
+
+/*
+proof.go builds and checks inclusion proofs: self contained evidence
+that a document was anchored in a given block.  The proof chains up to
+three steps, each a merkle proof or a hash:
+
+  document hash -> batch root    (only for documents anchored in a batch)
+  packet        -> packet root   (the block's merkle tree of packets)
+  block header  -> block hash
+
+so a verifier only needs the proof and the hash of the block at that
+index, rather than the block's other packets or the other documents of
+the batch.  A packet anchored as a record instead leads to the block's
+records hash, which is a plain hash of its record hashes rather than a
+merkle tree, so the proof carries the hashes of all the block's records
+and its packet root.
+*/
+
+type InclusionProof struct {
+	DocumentHash []byte
+	InBatch      bool         // the packet anchors the root of a batch holding the document
+	BatchProof   []MerkleStep // the document's path to the batch root, empty for a batch of one
+	Packet       Packet
+	PacketProof  []MerkleStep
+	InRecord     bool     // the packet is anchored by a record rather than in the block's packets
+	PacketsHash  []byte   // the block's packet root, for a packet anchored by a record
+	RecordHashes [][]byte // the hashes of the block's records, for a packet anchored by a record
+	RecordIndex  int      // the position of the anchor record among them
+	BlockIndex   uint32
+	PrevHash     []byte
+	RecordsHash  []byte
+	Nonce        uint32
+	Signer       []byte // the authority that sealed the block, under proof of authority
+	BlockHash    []byte
+}
+
+// proves the packet with the given hash and owner is in the blockchain,
+// whether in a block's packets or anchored by a record
+func (blockchain Blockchain) createInclusionProof(packetHash, publicKey []byte) (InclusionProof, bool) {
+	for _, block := range blockchain.Blocks {
+		for i, packet := range blockPackets(block) {
+			if string(packet.Hash) != string(packetHash) || !packetHasOwner(packet, publicKey) {
+				continue
+			}
+			recordsHash := []byte{}
+			if len(block.Records) != 0 {
+				recordsHash = hashRecordList(block.Records)
+			}
+			proof := InclusionProof{DocumentHash: packet.Hash,
+			                        BatchProof:   []MerkleStep{},
+			                        Packet:       packet,
+			                        PacketProof:  []MerkleStep{},
+			                        BlockIndex:   block.Index,
+			                        PrevHash:     block.PrevHash,
+			                        RecordsHash:  recordsHash,
+			                        Nonce:        block.Nonce,
+			                        Signer:       block.Signer,
+			                        BlockHash:    block.Hash}
+			if i < len(block.Data) {
+				proof.PacketProof = merkleProof(packetLeaves(block.Data), i)
+			} else {
+				proof.InRecord    = true
+				proof.PacketsHash = hashPacketList(block.Data)
+				proof.RecordHashes, proof.RecordIndex = anchorRecordPath(block.Records, packet)
+			}
+			return proof, true
+		}
+	}
+	return InclusionProof{}, false
+}
+
+// the hashes of records and the position among them of the record anchoring packet
+func anchorRecordPath(records []Record, packet Packet) ([][]byte, int) {
+	anchorHash := hashRecord(newRecord(anchorRecord, packet))
+	hashes     := [][]byte{}
+	index      := -1
+	for i, record := range records {
+		hashes = append(hashes, hashRecord(record))
+		if index < 0 && string(hashes[i]) == string(anchorHash) {
+			index = i
+		}
+	}
+	return hashes, index
+}
+
+// proves a document of a batch is in the blockchain, through the packet anchoring the batch root
+func (blockchain Blockchain) createBatchInclusionProof(entry BatchEntry, batchRoot, publicKey []byte) (InclusionProof, bool) {
+	if !verifyBatchEntry(entry, batchRoot) {
+		return InclusionProof{}, false
+	}
+	proof, found := blockchain.createInclusionProof(batchRoot, publicKey)
+	if !found {
+		return InclusionProof{}, false
+	}
+	proof.DocumentHash = entry.DocumentHash
+	proof.InBatch      = true
+	proof.BatchProof   = entry.Proof
+	return proof, true
+}
+
+// checks every step of the proof; the caller must still check BlockHash is on its chain
+func verifyInclusionProof(proof InclusionProof) bool {
+	// the document leads to the hash signed in the packet
+	if proof.InBatch {
+		if !verifyMerkleProof(batchLeafHash(proof.DocumentHash), proof.BatchProof, proof.Packet.Hash) {
+			return false
+		}
+	} else if string(proof.DocumentHash) != string(proof.Packet.Hash) {
+		return false
+	}
+
+	if !verifyPacket(proof.Packet) {
+		return false
+	}
+
+	// the packet leads to the block's packet root, or to its records hash
+	// when anchored by a record, which with the header gives the block hash
+	packetsHash := merkleRootFromProof(merkleLeafHash(hashPacket(proof.Packet)), proof.PacketProof)
+	if proof.InRecord {
+		if proof.RecordIndex < 0 || proof.RecordIndex >= len(proof.RecordHashes) {
+			return false
+		}
+		anchorHash := hashRecord(newRecord(anchorRecord, proof.Packet))
+		if string(proof.RecordHashes[proof.RecordIndex]) != string(anchorHash) || string(hashRecordHashes(proof.RecordHashes)) != string(proof.RecordsHash) {
+			return false
+		}
+		packetsHash = proof.PacketsHash
+	}
+	blockHash := hashBlockHeader(proof.BlockIndex, proof.PrevHash, packetsHash, proof.RecordsHash, proof.Nonce, proof.Signer)
+	return string(blockHash) == string(proof.BlockHash)
+}
+
+// checks the proof and that the block it ends in is part of this blockchain
+func (blockchain Blockchain) verifyInclusionProof(proof InclusionProof) bool {
+	if !verifyInclusionProof(proof) || int(proof.BlockIndex) >= len(blockchain.Blocks) {
+		return false
+	}
+	return string(blockchain.Blocks[proof.BlockIndex].Hash) == string(proof.BlockHash)
+}
+
+func writeInclusionProof(proof InclusionProof, filePath string) error {
+	data, err := json.MarshalIndent(proof, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, data, 0644)
+}
+
+func readInclusionProof(filePath string) (InclusionProof, error) {
+	proof := InclusionProof{}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return proof, err
+	}
+	err = json.Unmarshal(data, &proof)
+	return proof, err
+}
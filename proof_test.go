@@ -0,0 +1,147 @@
+package main
+
+import(
+	"path/filepath"
+	"testing"
+)
+
+func TestInclusionProof(t *testing.T){
+	useDifficulty(t, 4294967295) // all hashes pass
+
+	keys01 := GenerateNewKeypair()
+	batch, _ := createTestBatch(t)
+	batchPacket := createBatchPacket(batch, *keys01, "build 42")
+	if !verifyPacket(batchPacket) {
+		t.Fatal("fails to approve batch packet")
+	}
+
+	// block with several packets so the packet proof is not trivial
+	packets := []Packet{createPacket("document.txt", *GenerateNewKeypair()),
+						batchPacket,
+						createPacket("document.txt", *GenerateNewKeypair())}
+	g  := &genesisBlock
+	b1 := &Block{Index: g.Index + 1, PrevHash: g.Hash, Data: packets,
+				 Records: []Record{newRecord(identityRecord, createIdentityClaim(*keys01, "Builder"))}}
+	b1.Hash = b1.calcHashForBlock(0)
+	chain := Blockchain{Blocks: []Block{*g, *b1}}
+
+	// directly anchored packet
+	proof, found := chain.createInclusionProof(packets[2].Hash, packets[2].Owner)
+	if !found || !chain.verifyInclusionProof(proof) {
+		t.Error("fails to prove directly anchored packet")
+	}
+
+	// every document of the batch
+	for _, entry := range batch.Documents {
+		proof, found := chain.createBatchInclusionProof(entry, batch.Root, keys01.Public)
+		if !found || !chain.verifyInclusionProof(proof) {
+			t.Errorf("fails to prove batched document %v", entry.Path)
+		}
+	}
+
+	// tampering with any step breaks the proof
+	proof, _ = chain.createBatchInclusionProof(batch.Documents[1], batch.Root, keys01.Public)
+	tampered := proof
+	tampered.DocumentHash = hashDocument([]byte("other"))
+	if verifyInclusionProof(tampered) {
+		t.Error("verifies proof for a different document")
+	}
+	tampered = proof
+	tampered.InBatch = false
+	if verifyInclusionProof(tampered) {
+		t.Error("verifies batch proof as a proof of the packet alone")
+	}
+	tampered = proof
+	tampered.Packet.Memo = "build 43"
+	if verifyInclusionProof(tampered) {
+		t.Error("verifies proof with a changed packet")
+	}
+	tampered = proof
+	tampered.Nonce = 1
+	if verifyInclusionProof(tampered) {
+		t.Error("verifies proof with a changed block header")
+	}
+
+	// a proof for a block that is not on this chain is rejected
+	otherChain := Blockchain{Blocks: []Block{*g}}
+	if otherChain.verifyInclusionProof(proof) {
+		t.Error("verifies proof against a chain without its block")
+	}
+
+	// proofs survive being written and read back
+	proofPath := filepath.Join(t.TempDir(), "document.proof.json")
+	if err := writeInclusionProof(proof, proofPath); err != nil {
+		t.Fatal(err)
+	}
+	read, err := readInclusionProof(proofPath)
+	if err != nil || !chain.verifyInclusionProof(read) {
+		t.Error("fails to verify proof read from disk")
+	}
+}
+
+func TestSingleDocumentBatchProof(t *testing.T){
+	useDifficulty(t, 4294967295) // all hashes pass
+
+	keys01   := GenerateNewKeypair()
+	batch, _ := createTestBatch(t)
+	single, err := buildBatch([]string{batch.Documents[0].Path}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(single.Documents[0].Proof) != 0 {
+		t.Fatal("batch of one has a non empty proof")
+	}
+
+	g  := &genesisBlock
+	b1 := &Block{Index: g.Index + 1, PrevHash: g.Hash, Data: []Packet{createBatchPacket(single, *keys01, "")}}
+	b1.Hash = b1.calcHashForBlock(0)
+	chain := Blockchain{Blocks: []Block{*g, *b1}}
+
+	proof, valid := chain.createBatchInclusionProof(single.Documents[0], single.Root, keys01.Public)
+	if !valid || !chain.verifyInclusionProof(proof) {
+		t.Error("fails to prove the document of a batch of one")
+	}
+
+	// the batch root can not pass for the document itself
+	proof.DocumentHash = single.Root
+	if verifyInclusionProof(proof) {
+		t.Error("verifies the batch root as a document of its own batch")
+	}
+}
+
+func TestAnchorRecordInclusionProof(t *testing.T){
+	useDifficulty(t, 4294967295) // all hashes pass
+
+	keys01   := GenerateNewKeypair()
+	anchored := createPacket("document.txt", *keys01)
+	g  := &genesisBlock
+	b1 := &Block{Index: g.Index + 1, PrevHash: g.Hash, Data: []Packet{createPacket("document.txt", *GenerateNewKeypair())},
+				 Records: []Record{newRecord(identityRecord, createIdentityClaim(*keys01, "Builder")),
+								   newRecord(anchorRecord, anchored)}}
+	b1.Hash = b1.calcHashForBlock(0)
+	chain := Blockchain{Blocks: []Block{*g, *b1}}
+
+	proof, found := chain.createInclusionProof(anchored.Hash, keys01.Public)
+	if !found || !proof.InRecord || proof.RecordIndex != 1 {
+		t.Fatal("fails to find packet anchored by a record")
+	}
+	if !chain.verifyInclusionProof(proof) {
+		t.Error("fails to prove packet anchored by a record")
+	}
+
+	tampered := proof
+	tampered.RecordIndex = 0
+	if verifyInclusionProof(tampered) {
+		t.Error("verifies proof pointing at another record")
+	}
+	tampered = proof
+	tampered.RecordHashes = [][]byte{proof.RecordHashes[1]}
+	if verifyInclusionProof(tampered) {
+		t.Error("verifies proof leaving out the block's other records")
+	}
+	tampered = proof
+	tampered.PacketsHash = hashPacketList([]Packet{})
+	if verifyInclusionProof(tampered) {
+		t.Error("verifies proof with another packet root")
+	}
+}
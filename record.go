@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"fmt"
+)
+
+/*
+record.go holds the typed records stored on chain next to a block's
+packets.  A record is a type tag and a gob encoded payload.  Every type
+registers hooks to validate its payload and to hash it into the block
+commitment, so new kinds of record can be added by registering a type
+instead of changing Block.
+
+0 - reserved
+1 - document anchor, a Packet (see record.go)
+2 - key rotation or revocation (see keyrecord.go)
+3 - identity claim (see claims.go)
+4 - annotation (see claims.go)
+5 - authority change (see poa.go)
+*/
+
+const (
+	anchorRecord     uint8 = 1
+	keyRecordType    uint8 = 2
+	identityRecord   uint8 = 3
+	annotationRecord uint8 = 4
+)
+
+type Record struct {
+	Type    uint8
+	Payload []byte
+}
+
+type recordType struct {
+	name     string
+	validate func(record Record, block *Block) bool // called from isValidNextBlock, block is nil for records not yet mined
+	hash     func(record Record) []byte             // committed to by the block hash
+	describe func(record Record) string
+}
+
+var recordTypes = map[uint8]recordType{}
+
+func registerRecordType(typ uint8, rt recordType) {
+	if _, exists := recordTypes[typ]; exists || typ == 0 {
+		panic(fmt.Sprintf("record type %v registered twice", typ))
+	}
+	recordTypes[typ] = rt
+}
+
+func init() {
+	registerRecordType(anchorRecord, recordType{
+		name: "anchor",
+		validate: func(record Record, block *Block) bool {
+			var packet Packet
+			return decodeRecord(record, &packet) == nil && verifyPacket(packet)
+		},
+		hash: func(record Record) []byte {
+			var packet Packet
+			decodeRecord(record, &packet)
+			return hashPacketList([]Packet{packet})
+		},
+		describe: func(record Record) string {
+			var packet Packet
+			decodeRecord(record, &packet)
+			return fmt.Sprintf("anchor of %x", packet.Hash)
+		},
+	})
+}
+
+func newRecord(typ uint8, value interface{}) Record {
+	var buffer bytes.Buffer
+	gob.NewEncoder(&buffer).Encode(value)
+	return Record{Type: typ, Payload: buffer.Bytes()}
+}
+
+func decodeRecord(record Record, value interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(record.Payload)).Decode(value)
+}
+
+func verifyRecord(record Record, block *Block) bool {
+	rt, known := recordTypes[record.Type]
+	if !known {
+		return false // nodes can not agree on records they do not understand
+	}
+	return rt.validate(record, block)
+}
+
+func verifyRecordList(records []Record, block *Block) bool {
+	for _, record := range records {
+		if verifyRecord(record, block) == false {
+			return false
+		}
+	}
+	return true
+}
+
+func hashRecord(record Record) []byte {
+	h := sha256.New()
+	h.Write([]byte{record.Type})
+	if rt, known := recordTypes[record.Type]; known {
+		h.Write(rt.hash(record))
+	} else {
+		h.Write(record.Payload)
+	}
+	return h.Sum(nil)
+}
+
+func hashRecordList(records []Record) []byte {
+	hashes := [][]byte{}
+	for _, record := range records {
+		hashes = append(hashes, hashRecord(record))
+	}
+	return hashRecordHashes(hashes)
+}
+
+// the records hash of a block from the hashes of its records, in order
+func hashRecordHashes(hashes [][]byte) []byte {
+	h := sha256.New()
+	for _, hash := range hashes {
+		h.Write(hash)
+	}
+	return h.Sum(nil)
+}
+
+func describeRecord(record Record) string {
+	rt, known := recordTypes[record.Type]
+	if !known {
+		return fmt.Sprintf("unknown record type %v", record.Type)
+	}
+	return rt.describe(record)
+}
+
+func recordListHasRecord(records []Record, recordInQuestion Record) bool {
+	for _, record := range records {
+		if string(hashRecord(record)) == string(hashRecord(recordInQuestion)) {
+			return true
+		}
+	}
+	return false
+}
+
+// all packets anchored in a block, whether in its packet list or as anchor records
+func blockPackets(block Block) []Packet {
+	packets := block.Data
+	for _, record := range block.Records {
+		var packet Packet
+		if record.Type == anchorRecord && decodeRecord(record, &packet) == nil {
+			packets = append(packets[:len(packets):len(packets)], packet)
+		}
+	}
+	return packets
+}
@@ -0,0 +1,69 @@
+package main
+
+import(
+	"testing"
+)
+
+func TestVerifyRecordList(t *testing.T){
+	keys01 := GenerateNewKeypair()
+	packet := createPacket("document.txt", *keys01)
+
+	records := []Record{newRecord(anchorRecord, packet),
+						newRecord(keyRecordType, createKeyRecord(keyRevocation, *keys01, []byte{}, 0)),
+						newRecord(identityRecord, createIdentityClaim(*keys01, "Nick"))}
+	if !verifyRecordList(records, nil) {
+		t.Error("fails to validate valid records")
+	}
+
+	// unknown types are rejected
+	if verifyRecord(Record{Type: 200, Payload: []byte("anything")}, nil) {
+		t.Error("validates record of unknown type")
+	}
+
+	// payload that does not decode is rejected
+	if verifyRecord(Record{Type: anchorRecord, Payload: []byte("garbage")}, nil) {
+		t.Error("validates record with a corrupt payload")
+	}
+
+	// invalid anchored packet is rejected
+	packet.Owner = GenerateNewKeypair().Public
+	if verifyRecord(newRecord(anchorRecord, packet), nil) {
+		t.Error("validates anchor of an invalid packet")
+	}
+}
+
+func TestRecordsInBlock(t *testing.T){
+	useDifficulty(t, 4294967295) // all hashes pass
+
+	keys01 := GenerateNewKeypair()
+	packet := createPacket("document.txt", *keys01)
+
+	g  := &genesisBlock
+	b1 := &Block{Index: g.Index + 1, PrevHash: g.Hash, Data: []Packet{},
+				 Records: []Record{newRecord(anchorRecord, packet)}}
+	b1.Hash = b1.calcHashForBlock(0)
+	if !g.isValidNextBlock(b1) {
+		t.Error("fails to validate block with an anchor record")
+	}
+
+	// packets anchored as records are found by lookup
+	chain := Blockchain{Blocks: []Block{*g, *b1}}
+	if !equalPackets(chain.findPacketByHashAndPublicKey(packet.Hash, keys01.Public), packet) {
+		t.Error("fails to find packet anchored as a record")
+	}
+
+	// block hash commits to the records
+	b2 := *b1
+	b2.Records = []Record{newRecord(anchorRecord, createPacket("document.txt", *GenerateNewKeypair()))}
+	if string(b2.calcHashForBlock(0)) == string(b1.Hash) {
+		t.Error("block hash does not commit to its records")
+	}
+
+	// block with a record of unknown type is invalid
+	b3 := *b1
+	b3.Records = []Record{{Type: 200, Payload: []byte("anything")}}
+	b3.Hash = b3.calcHashForBlock(0)
+	if g.isValidNextBlock(&b3) {
+		t.Error("validates block with a record of unknown type")
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+/*
+seeds.go finds the bootstrap peers a node joins the network through.
+Seeds come from the command line, the config file, a seeds file and a
+DNS name whose TXT records list seed addresses.  They are tried in a
+random order, so every node does not crowd the first seed, and the node
+falls back to the next one until a dial succeeds.
+*/
+
+const seedDialTimeout = 5 * time.Second
+
+// anything that can list seed addresses
+type SeedResolver interface {
+	resolveSeeds() ([]string, error)
+}
+
+// seeds listed directly, from flags or the config file
+type staticSeedResolver []string
+
+func (seeds staticSeedResolver) resolveSeeds() ([]string, error) {
+	return []string(seeds), nil
+}
+
+// a file with one or more seeds per line, ignoring blank lines and lines starting with #
+type fileSeedResolver struct {
+	path string
+}
+
+func (r fileSeedResolver) resolveSeeds() ([]string, error) {
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return nil, err
+	}
+	return parseSeedList(strings.Split(string(data), "\n")), nil
+}
+
+// the TXT records of a DNS name, each holding one or more seeds
+type dnsSeedResolver struct {
+	name      string
+	lookupTXT func(name string) ([]string, error) // net.LookupTXT, replaced in tests
+}
+
+func newDNSSeedResolver(name string) dnsSeedResolver {
+	return dnsSeedResolver{name: name, lookupTXT: net.LookupTXT}
+}
+
+func (r dnsSeedResolver) resolveSeeds() ([]string, error) {
+	records, err := r.lookupTXT(r.name)
+	if err != nil {
+		return nil, err
+	}
+	return parseSeedList(records), nil
+}
+
+// splits lines on whitespace and commas, dropping comments
+func parseSeedList(lines []string) []string {
+	seeds := []string{}
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		seeds = append(seeds, strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })...)
+	}
+	return seeds
+}
+
+// turns a seed into an address to dial: host:port is used as is, otherwise a
+// public seed is an IP listening on the default port and a private seed is a
+// port on this machine, reached at localHost
+func seedAddress(seed string, publicFlag bool, defaultPort string, localHost string) string {
+	if strings.Contains(seed, ":") {
+		return seed
+	}
+	if publicFlag {
+		return seed + ":" + defaultPort
+	}
+	return localHost + ":" + seed
+}
+
+// gathers the seeds of every resolver, without duplicates, in a random order;
+// a resolver that fails is reported and skipped
+func resolveSeeds(resolvers []SeedResolver, publicFlag bool, defaultPort string, localHost string) []string {
+	addresses := []string{}
+	for _, resolver := range resolvers {
+		seeds, err := resolver.resolveSeeds()
+		if err != nil {
+			p2pLog.Warn("unable to resolve seeds", "err", err)
+			continue
+		}
+		for _, seed := range seeds {
+			addresses = addUniqueAddresses(addresses, []string{seedAddress(seed, publicFlag, defaultPort, localHost)})
+		}
+	}
+	rand.Shuffle(len(addresses), func(i, j int) { addresses[i], addresses[j] = addresses[j], addresses[i] })
+	return addresses
+}
+
+// dials each seed in turn until one answers, returning the connection and the seed's address
+func dialSeeds(seeds []string, dial func(address string) (net.Conn, error)) (net.Conn, string, bool) {
+	for _, address := range seeds {
+		conn, err := dial(address)
+		if err != nil {
+			p2pLog.Info("seed did not answer, trying the next one", "seed", address, "err", err)
+			continue
+		}
+		return conn, address, true
+	}
+	return nil, "", false
+}
+
+func dialWithTimeout(address string) (net.Conn, error) {
+	return net.DialTimeout("tcp", address, seedDialTimeout)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseSeedList(t *testing.T) {
+	seeds := parseSeedList([]string{"# seeds", "", " 1999, 2000 ", "10.0.0.1:1999\t10.0.0.2:1999"})
+	if len(seeds) != 4 || seeds[0] != "1999" || seeds[3] != "10.0.0.2:1999" {
+		t.Error("parseSeedList did not split the seeds on commas and whitespace", seeds)
+	}
+}
+
+func TestSeedResolvers(t *testing.T) {
+	seedsPath := filepath.Join(t.TempDir(), "seeds.txt")
+	os.WriteFile(seedsPath, []byte("# bootstrap nodes\n10.0.0.1:1999\n\n10.0.0.2:1999\n"), 0644)
+	seeds, err := fileSeedResolver{path: seedsPath}.resolveSeeds()
+	if err != nil || len(seeds) != 2 {
+		t.Error("fileSeedResolver did not read the seeds file")
+	}
+
+	records  := map[string][]string{"seeds.example.org": {"10.0.0.3:1999 10.0.0.4:1999", "10.0.0.1:1999"}}
+	resolver := dnsSeedResolver{name: "seeds.example.org", lookupTXT: func(name string) ([]string, error) {
+		if txt, found := records[name]; found {
+			return txt, nil
+		}
+		return nil, errors.New("no such host")
+	}}
+	seeds, err = resolver.resolveSeeds()
+	if err != nil || len(seeds) != 3 {
+		t.Error("dnsSeedResolver did not read every TXT record")
+	}
+
+	// seeds of every resolver are gathered once each, a failing resolver is skipped
+	missing   := fileSeedResolver{path: filepath.Join(t.TempDir(), "missing.txt")}
+	addresses := resolveSeeds([]SeedResolver{staticSeedResolver{"10.0.0.1:1999"}, fileSeedResolver{path: seedsPath}, missing, resolver}, false, "1999", getPrivateIP())
+	if len(addresses) != 4 {
+		t.Error("resolveSeeds should gather each seed once", addresses)
+	}
+	for _, address := range []string{"10.0.0.1:1999", "10.0.0.2:1999", "10.0.0.3:1999", "10.0.0.4:1999"} {
+		if !containsAddress(addresses, address) {
+			t.Error("resolveSeeds is missing seed " + address)
+		}
+	}
+}
+
+func TestSeedAddress(t *testing.T) {
+	if seedAddress("10.0.0.1:2000", true, "1999", getPrivateIP()) != "10.0.0.1:2000" {
+		t.Error("host:port seeds should be used as is")
+	}
+	if seedAddress("10.0.0.1", true, "1999", getPrivateIP()) != "10.0.0.1:1999" {
+		t.Error("public seeds should listen on the default port")
+	}
+	if seedAddress("2000", false, "1999", getPrivateIP()) != getPrivateIP()+":2000" {
+		t.Error("private seeds should be a port on this machine")
+	}
+}
+
+func TestDialSeedsFallsBack(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			conn.Close()
+		}
+	}()
+
+	// a port nobody listens on, taken from a closed listener
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	closed.Close()
+
+	conn, address, joined := dialSeeds([]string{closed.Addr().String(), listener.Addr().String()}, dialWithTimeout)
+	if !joined || address != listener.Addr().String() {
+		t.Error("dialSeeds did not fall back to the seed that answered")
+	} else {
+		conn.Close()
+	}
+
+	if _, _, joined := dialSeeds([]string{closed.Addr().String()}, dialWithTimeout); joined {
+		t.Error("dialSeeds joined although no seed answered")
+	}
+}
@@ -0,0 +1,452 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// a network of nodes in one process, joined by net.Pipe connections instead
+// of TCP, so tests can decide who talks to whom and control latency,
+// partitions and dropped messages
+type simNetwork struct {
+	t       *testing.T
+	nodes   []*Node
+	stopped []chan bool
+	cancel  context.CancelFunc
+	keys    *Keypair // signs the packets of the blocks mineBlock builds
+
+	mu       sync.Mutex // guards the fields below
+	latency  time.Duration
+	dropRate float64
+	random   *rand.Rand
+	groups   map[*Node]int // the side of a partition each node is on
+}
+
+// starts count nodes that are not yet connected; they are stopped when the test ends
+func newSimNetwork(t *testing.T, count int) *simNetwork {
+	useDifficulty(t, 4294967295) // all hashes pass, so blocks need no mining
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sim := &simNetwork{t:      t,
+	                   cancel: cancel,
+	                   keys:   GenerateNewKeypair(),
+	                   random: rand.New(rand.NewSource(1)), // the same drops every run
+	                   groups: map[*Node]int{}}
+	for i := 0; i < count; i++ {
+		n        := newNode()
+		n.address = fmt.Sprintf("10.0.0.%v:1999", i+1)
+		n.ctx, n.stop = context.WithCancel(ctx)
+		stopped  := make(chan bool)
+		go func() {
+			n.serve(n.ctx)
+			close(stopped)
+		}()
+		sim.nodes   = append(sim.nodes, n)
+		sim.stopped = append(sim.stopped, stopped)
+	}
+	t.Cleanup(sim.stop)
+	return sim
+}
+
+func (sim *simNetwork) stop() {
+	sim.cancel()
+	for _, stopped := range sim.stopped {
+		<-stopped
+	}
+}
+
+// connects two nodes, as if a had dialed b
+func (sim *simNetwork) connect(a *Node, b *Node) {
+	endA, endB := net.Pipe()
+	a.newConnChannel <- newSimConn(sim, endA, a, b)
+	b.newConnChannel <- newSimConn(sim, endB, b, a)
+}
+
+// connects every node to every other node
+func (sim *simNetwork) connectAll() {
+	for i := range sim.nodes {
+		for j := i + 1; j < len(sim.nodes); j++ {
+			sim.connect(sim.nodes[i], sim.nodes[j])
+		}
+	}
+	sim.waitForPeers(len(sim.nodes) - 1)
+}
+
+// connects the nodes in a line, each to the next
+func (sim *simNetwork) connectLine() {
+	for i := 1; i < len(sim.nodes); i++ {
+		sim.connect(sim.nodes[i-1], sim.nodes[i])
+	}
+	if !waitFor(5*time.Second, func() bool { return len(sim.nodes[len(sim.nodes)-1].getRemoteAddresses()) == 1 }) {
+		sim.t.Fatal("nodes did not connect")
+	}
+}
+
+func (sim *simNetwork) waitForPeers(count int) {
+	connected := waitFor(5*time.Second, func() bool {
+		for _, n := range sim.nodes {
+			if len(n.getRemoteAddresses()) != count {
+				return false
+			}
+		}
+		return true
+	})
+	if !connected {
+		sim.t.Fatal("nodes did not connect")
+	}
+}
+
+// delays every message by latency before it is delivered
+func (sim *simNetwork) setLatency(latency time.Duration) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	sim.latency = latency
+}
+
+// drops each message with the given probability, 0 for none and 1 for all
+func (sim *simNetwork) setDropRate(rate float64) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	sim.dropRate = rate
+}
+
+// splits the nodes into groups that can only reach the nodes of their own
+// group; nodes left out of every group form a group of their own
+func (sim *simNetwork) partition(groups ...[]*Node) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	sim.groups = map[*Node]int{}
+	for i, group := range groups {
+		for _, n := range group {
+			sim.groups[n] = i + 1
+		}
+	}
+}
+
+func (sim *simNetwork) heal() {
+	sim.partition()
+}
+
+// whether a message sent now from one node to another is lost, and if not how long it takes
+func (sim *simNetwork) route(from *Node, to *Node) (time.Duration, bool) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	if sim.groups[from] != sim.groups[to] {
+		return 0, false
+	}
+	if sim.dropRate > 0 && sim.random.Float64() < sim.dropRate {
+		return 0, false
+	}
+	return sim.latency, true
+}
+
+// builds the next block on n's blockchain, holding one new packet, and hands
+// it to n as if n had mined it; returns once n has added it
+func (sim *simNetwork) mineBlock(n *Node) Block {
+	sim.t.Helper()
+	lastBlock    := n.getBlockchain().getLastBlock()
+	documentHash := hashDocument([]byte(fmt.Sprintf("%v mined block %v", n.getAddress(), lastBlock.Index+1)))
+	packet       := Packet{Hash: documentHash, Owner: sim.keys.Public, Signature: signHash(documentHash, *sim.keys)}
+	block        := Block{Index:    lastBlock.Index + 1,
+	                      PrevHash: lastBlock.Hash,
+	                      Data:     []Packet{packet},
+	                      Records:  []Record{}}
+	block.Hash = block.calcHashForBlock(0)
+
+	n.blockWrapperChannel <- &BlockWrapper{Block: block, Sender: n.getAddress()}
+	if !waitFor(5*time.Second, func() bool { return sim.hasBlock(n, block) }) {
+		sim.t.Fatal("node did not add the block it mined")
+	}
+	return block
+}
+
+func (sim *simNetwork) hasBlock(n *Node, block Block) bool {
+	return string(n.getBlockchain().getLastBlock().Hash) == string(block.Hash)
+}
+
+// whether every node has the same last block
+func (sim *simNetwork) converged() bool {
+	tip := sim.nodes[0].getBlockchain().getLastBlock()
+	for _, n := range sim.nodes[1:] {
+		if !sim.hasBlock(n, tip) {
+			return false
+		}
+	}
+	return true
+}
+
+// the last block of every node, to report nodes that did not converge
+func (sim *simNetwork) tips() string {
+	tips := []string{}
+	for _, n := range sim.nodes {
+		lastBlock := n.getBlockchain().getLastBlock()
+		tips = append(tips, fmt.Sprintf("%v #%v %.8x", n.getAddress(), lastBlock.Index, lastBlock.Hash))
+	}
+	return strings.Join(tips, ", ")
+}
+
+func (sim *simNetwork) assertConverged(timeout time.Duration) {
+	sim.t.Helper()
+	if !waitFor(timeout, sim.converged) {
+		sim.t.Error("nodes did not converge on the same last block: " + sim.tips())
+	}
+}
+
+// the in-memory address of a node
+type simAddr string
+
+func (a simAddr) Network() string { return "sim" }
+func (a simAddr) String() string  { return string(a) }
+
+// one node's end of a connection in a simNetwork.  Reads come straight from
+// the pipe, while writes are split into gob messages which the network then
+// delays, or drops when they hold a communication, and passes on in order as
+// one stream of bytes: messages due together reach the pipe in one write, as
+// back to back writes to a TCP connection may.
+type simConn struct {
+	net.Conn
+	sim    *simNetwork
+	local  *Node
+	remote *Node
+	addrs  [2]simAddr // local then remote, fixed so they can be read under either node's lock
+
+	mu      sync.Mutex
+	pending []byte // written, but not yet a whole gob message
+	outbox  chan simMessage
+	closed  chan bool
+	once    sync.Once
+}
+
+type simMessage struct {
+	data      []byte
+	deliverAt time.Time
+}
+
+func newSimConn(sim *simNetwork, end net.Conn, local *Node, remote *Node) *simConn {
+	c := &simConn{Conn:   end,
+	              sim:    sim,
+	              local:  local,
+	              remote: remote,
+	              addrs:  [2]simAddr{simAddr(local.getAddress()), simAddr(remote.getAddress())},
+	              outbox: make(chan simMessage, 1024),
+	              closed: make(chan bool)}
+	go c.deliver()
+	return c
+}
+
+func (c *simConn) LocalAddr() net.Addr  { return c.addrs[0] }
+func (c *simConn) RemoteAddr() net.Addr { return c.addrs[1] }
+
+func (c *simConn) Write(buffer []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-c.closed:
+		return 0, io.ErrClosedPipe
+	default:
+	}
+
+	c.pending = append(c.pending, buffer...)
+	for {
+		length, isValue, complete := gobMessageLength(c.pending)
+		if !complete {
+			break
+		}
+		message := simMessage{data: append([]byte{}, c.pending[:length]...)}
+		c.pending = c.pending[length:]
+
+		// the connection's decoder needs every type the encoder defines,
+		// so only the messages holding a communication are lost
+		latency, delivered := c.sim.route(c.local, c.remote)
+		if !delivered && isValue {
+			continue
+		}
+		message.deliverAt = time.Now().Add(latency)
+		select {
+		case c.outbox <- message:
+		case <-c.closed:
+			return 0, io.ErrClosedPipe
+		}
+	}
+	return len(buffer), nil
+}
+
+// writes the messages to the pipe once their latency has passed, together
+// with any others that are due by then
+func (c *simConn) deliver() {
+	for {
+		select {
+		case message := <-c.outbox:
+			select {
+			case <-time.After(time.Until(message.deliverAt)):
+			case <-c.closed:
+				return
+			}
+			data := message.data
+			for len(c.outbox) > 0 {
+				next := <-c.outbox
+				if wait := time.Until(next.deliverAt); wait > 0 {
+					time.Sleep(wait)
+				}
+				data = append(data, next.data...)
+			}
+			if _, err := c.Conn.Write(data); err != nil {
+				return
+			}
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+func (c *simConn) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return c.Conn.Close()
+}
+
+// the length of the gob message at the start of buffer, whether it holds a
+// value rather than defining a type, and whether buffer holds all of it yet
+func gobMessageLength(buffer []byte) (int, bool, bool) {
+	count, countSize, ok := readGobUint(buffer)
+	if !ok || len(buffer) < countSize+int(count) {
+		return 0, false, false
+	}
+	typeID, _, ok := readGobUint(buffer[countSize:])
+	if !ok {
+		return 0, false, false
+	}
+	return countSize + int(count), typeID&1 == 0, true // a positive type id is a value
+}
+
+// reads an unsigned integer as gob encodes it: a byte below 0x80 is the value,
+// otherwise it is the negated count of big endian bytes that follow
+func readGobUint(buffer []byte) (uint64, int, bool) {
+	if len(buffer) == 0 {
+		return 0, 0, false
+	}
+	if buffer[0] < 0x80 {
+		return uint64(buffer[0]), 1, true
+	}
+	size := int(-int8(buffer[0]))
+	if len(buffer) < 1+size {
+		return 0, 0, false
+	}
+	var value uint64
+	for _, b := range buffer[1 : 1+size] {
+		value = value<<8 | uint64(b)
+	}
+	return value, 1 + size, true
+}
+
+func TestSimulationConverges(t *testing.T) {
+	sim := newSimNetwork(t, 5)
+	sim.setLatency(5 * time.Millisecond)
+	sim.connectLine()
+
+	for i := 0; i < 3; i++ {
+		sim.mineBlock(sim.nodes[0])
+	}
+	sim.assertConverged(5 * time.Second)
+
+	// blocks travel back up the line too
+	block := sim.mineBlock(sim.nodes[4])
+	sim.assertConverged(5 * time.Second)
+	if block.Index != 4 || !sim.hasBlock(sim.nodes[0], block) {
+		t.Error("block mined at the end of the line did not reach the start: " + sim.tips())
+	}
+}
+
+func TestSimulationPartitionHeals(t *testing.T) {
+	sim := newSimNetwork(t, 4)
+	sim.connectAll()
+	sim.partition(sim.nodes[:2], sim.nodes[2:])
+
+	// each side of the partition mines its own block 1
+	block1 := sim.mineBlock(sim.nodes[0])
+	fork   := sim.mineBlock(sim.nodes[2])
+	if !waitFor(5*time.Second, func() bool { return sim.hasBlock(sim.nodes[1], block1) && sim.hasBlock(sim.nodes[3], fork) }) {
+		t.Fatal("blocks did not spread within their side of the partition: " + sim.tips())
+	}
+	time.Sleep(50 * time.Millisecond)
+	if sim.hasBlock(sim.nodes[2], block1) || sim.hasBlock(sim.nodes[1], fork) {
+		t.Error("block crossed the partition: " + sim.tips())
+	}
+
+	// once healed, the next block is ahead of the other side, which fetches the whole chain
+	sim.heal()
+	block2 := sim.mineBlock(sim.nodes[0])
+	sim.assertConverged(5 * time.Second)
+	if !sim.hasBlock(sim.nodes[3], block2) {
+		t.Error("nodes did not converge on the longer chain: " + sim.tips())
+	}
+}
+
+func TestSimulationDropsMessages(t *testing.T) {
+	sim := newSimNetwork(t, 3)
+	sim.connectAll()
+
+	sim.setDropRate(1)
+	block1 := sim.mineBlock(sim.nodes[0])
+	time.Sleep(50 * time.Millisecond)
+	if sim.hasBlock(sim.nodes[1], block1) || sim.hasBlock(sim.nodes[2], block1) {
+		t.Error("dropped block reached a peer: " + sim.tips())
+	}
+
+	// peers that missed a block catch up on the next one
+	sim.setDropRate(0)
+	sim.mineBlock(sim.nodes[0])
+	sim.assertConverged(5 * time.Second)
+}
+
+func TestGobMessageLength(t *testing.T) {
+	out  := &strings.Builder{}
+	conn := writerConn{out}
+	writeCommunication(conn, Communication{ID: 2})
+	encoded := []byte(out.String())
+
+	// the first communication defines its types before the value
+	values := 0
+	for start := 0; start < len(encoded); {
+		length, isValue, complete := gobMessageLength(encoded[start:])
+		if !complete {
+			t.Fatal("Gob message was not found whole at", start)
+		}
+		if isValue {
+			values = values + 1
+		}
+		start = start + length
+	}
+	if values != 1 {
+		t.Error("Communication was not sent as one value, got", values)
+	}
+	if _, _, complete := gobMessageLength(encoded[:1]); complete {
+		t.Error("Part of a gob message was taken as whole")
+	}
+
+	// the types are only sent once for each connection
+	out.Reset()
+	writeCommunication(conn, Communication{ID: 4})
+	if length, isValue, complete := gobMessageLength([]byte(out.String())); !complete || !isValue || length != out.Len() {
+		t.Error("Second communication on a connection was not sent as a value alone")
+	}
+}
+
+// a connection that only writes, to capture what writeCommunication sends
+type writerConn struct {
+	io.Writer
+}
+
+func (c writerConn) Read(buffer []byte) (int, error)       { return 0, io.EOF }
+func (c writerConn) Close() error                         { return nil }
+func (c writerConn) LocalAddr() net.Addr                  { return simAddr("local") }
+func (c writerConn) RemoteAddr() net.Addr                 { return simAddr("remote") }
+func (c writerConn) SetDeadline(t time.Time) error        { return nil }
+func (c writerConn) SetReadDeadline(t time.Time) error    { return nil }
+func (c writerConn) SetWriteDeadline(t time.Time) error   { return nil }
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+/*
+store.go keeps a node's blockchain and the addresses of the peers it
+knows about in its data directory, so a restarted node does not have to
+download the whole chain again or be told where its peers are.  Both are
+held in memory while the node runs and are flushed when it shuts down.
+A node started without a data directory keeps nothing.
+*/
+
+const blockStoreFile = "blockchain.dat"
+const peerStoreFile  = "peers.json"
+
+// writes to a temporary file first, so a crash never leaves a half written store
+func writeFileAtomic(filePath string, write func(file *os.File) error) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), filePath)
+}
+
+func saveBlockchain(dataDir string, blockchain Blockchain) error {
+	return writeFileAtomic(filepath.Join(dataDir, blockStoreFile), func(file *os.File) error {
+		return gob.NewEncoder(file).Encode(blockchain)
+	})
+}
+
+// returns an empty blockchain when the store does not exist yet
+func loadBlockchain(dataDir string) (Blockchain, error) {
+	blockchain := Blockchain{}
+	file, err := os.Open(filepath.Join(dataDir, blockStoreFile))
+	if os.IsNotExist(err) {
+		return blockchain, nil
+	}
+	if err != nil {
+		return blockchain, err
+	}
+	defer file.Close()
+	err = gob.NewDecoder(file).Decode(&blockchain)
+	return blockchain, err
+}
+
+func savePeers(dataDir string, peers []string) error {
+	return writeFileAtomic(filepath.Join(dataDir, peerStoreFile), func(file *os.File) error {
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(peers)
+	})
+}
+
+// returns no peers when the store does not exist yet
+func loadPeers(dataDir string) ([]string, error) {
+	peers := []string{}
+	data, err := os.ReadFile(filepath.Join(dataDir, peerStoreFile))
+	if os.IsNotExist(err) {
+		return peers, nil
+	}
+	if err != nil {
+		return peers, err
+	}
+	err = json.Unmarshal(data, &peers)
+	return peers, err
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveAndLoadBlockchain(t *testing.T) {
+	useDifficulty(t, 4294967295) // all hashes pass
+	dataDir := filepath.Join(t.TempDir(), "node")
+
+	blockchain, err := loadBlockchain(dataDir)
+	if err != nil || len(blockchain.Blocks) != 0 {
+		t.Error("Missing block store should load as an empty blockchain")
+	}
+
+	keys       := GenerateNewKeypair()
+	hash       := hashDocument([]byte("stored"))
+	block      := Block{Index: 1, PrevHash: genesisBlock.Hash, Data: []Packet{{Hash: hash, Owner: keys.Public, Signature: signHash(hash, *keys)}}}
+	block.Hash  = block.calcHashForBlock(0)
+	blockchain  = Blockchain{Blocks: []Block{genesisBlock, block}}
+
+	if err := saveBlockchain(dataDir, blockchain); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadBlockchain(dataDir)
+	if err != nil || len(loaded.Blocks) != 2 || !loaded.isValidChain() {
+		t.Error("Saved blockchain does not load back")
+	}
+
+	entries, _ := os.ReadDir(dataDir)
+	if len(entries) != 1 {
+		t.Error("Saving the blockchain left temporary files behind")
+	}
+}
+
+func TestSaveAndLoadPeers(t *testing.T) {
+	dataDir := t.TempDir()
+
+	peers, err := loadPeers(dataDir)
+	if err != nil || len(peers) != 0 {
+		t.Error("Missing peer store should load as no peers")
+	}
+
+	if err := savePeers(dataDir, []string{"10.0.0.1:1999", "10.0.0.2:1999"}); err != nil {
+		t.Fatal(err)
+	}
+	peers, err = loadPeers(dataDir)
+	if err != nil || len(peers) != 2 || peers[1] != "10.0.0.2:1999" {
+		t.Error("Saved peers do not load back")
+	}
+}
+
+func TestLoadStoresChecksGenesisBlock(t *testing.T) {
+	dataDir := t.TempDir()
+	foreign := Block{Index: 0, PrevHash: []byte{0}, Data: []Packet{}}
+	foreign.Hash = foreign.calcHashForBlock(0)
+	if err := saveBlockchain(dataDir, Blockchain{Blocks: []Block{foreign}}); err != nil {
+		t.Fatal(err)
+	}
+
+	n := newNode()
+	n.config.Storage.DataDir = dataDir
+	n.loadStores()
+	if blockchain := n.getBlockchain(); len(blockchain.Blocks) != 1 || string(blockchain.Blocks[0].Hash) != string(genesisBlock.Hash) {
+		t.Error("Loaded a saved blockchain whose only block is not the genesis block")
+	}
+
+	if err := saveBlockchain(dataDir, Blockchain{Blocks: []Block{genesisBlock}}); err != nil {
+		t.Fatal(err)
+	}
+	n.loadStores()
+	if len(n.getBlockchain().Blocks) != 1 {
+		t.Error("Saved blockchain of only the genesis block did not load")
+	}
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+/*
+transport.go is how a node reaches its peers.  The node listens and dials
+through a Transport, picked by network.transport in the config:
+
+  tcp     plain TCP, the default
+  tls     TCP encrypted with TLS 1.3, where both sides authenticate
+          with their identity key, see identity.go, or with
+          network.tls_cert and tls_key; with network.tls_ca, peers must
+          also present a certificate signed by that CA
+  memory  connections within the process, for tests and simulations
+
+Whatever the transport, the node exchanges the same gob communications
+over the net.Conn it returns.
+*/
+
+type Transport interface {
+	listen(port string) (net.Listener, error) // port is ":1999"
+	dial(address string) (net.Conn, error)
+	localHost() string // the host a peer on this machine dials to reach the node
+}
+
+const handshakeTimeout = 10 * time.Second
+
+// the transport network.transport names; identity is the key the tls transport authenticates with
+func newTransport(config NetworkConfig, identity ed25519.PrivateKey) (Transport, error) {
+	switch config.Transport {
+	case "", "tcp":
+		return tcpTransport{}, nil
+	case "memory":
+		return memoryTransport{network: sharedMemoryNetwork}, nil
+	case "tls":
+		tlsConfig, err := newTLSConfig(config, identity)
+		if err != nil {
+			return nil, err
+		}
+		return tlsTransport{config: tlsConfig, id: peerIDOfCertificate(tlsConfig.Certificates[0].Certificate[0])}, nil
+	default:
+		return nil, fmt.Errorf("unknown transport %q", config.Transport)
+	}
+}
+
+type tcpTransport struct{}
+
+func (tcpTransport) listen(port string) (net.Listener, error) {
+	return net.Listen("tcp", port)
+}
+
+func (tcpTransport) dial(address string) (net.Conn, error) {
+	return dialWithTimeout(address)
+}
+
+func (tcpTransport) localHost() string {
+	return getPrivateIP()
+}
+
+type tlsTransport struct {
+	config *tls.Config
+	id     string // the ID peers know this node by
+}
+
+func (t tlsTransport) listen(port string) (net.Listener, error) {
+	return tls.Listen("tcp", port, t.config)
+}
+
+func (t tlsTransport) dial(address string) (net.Conn, error) {
+	return tls.DialWithDialer(&net.Dialer{Timeout: seedDialTimeout}, "tcp", address, t.config)
+}
+
+func (t tlsTransport) localHost() string {
+	return getPrivateIP()
+}
+
+// the TLS settings of a node, which both accepts and dials peers with the same
+// certificate and asks every peer for theirs.  Peers are dialed by IP and
+// change address, so a peer's certificate is checked against the CA and the
+// allowed peers but not against the address it was dialed at.
+func newTLSConfig(config NetworkConfig, identity ed25519.PrivateKey) (*tls.Config, error) {
+	var certificate tls.Certificate
+	var err error
+	if config.TLSCert != "" {
+		certificate, err = tls.LoadX509KeyPair(config.TLSCert, config.TLSKey)
+	} else {
+		certificate, err = identityCertificate(identity)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var pool *x509.CertPool
+	if config.TLSCA != "" {
+		caPEM, err := os.ReadFile(config.TLSCA)
+		if err != nil {
+			return nil, err
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in %v", config.TLSCA)
+		}
+	}
+
+	return &tls.Config{Certificates:          []tls.Certificate{certificate},
+	                   MinVersion:            tls.VersionTLS13,
+	                   ClientAuth:            tls.RequireAnyClientCert,
+	                   InsecureSkipVerify:    true, // checked by verifyPeerCertificate instead
+	                   VerifyPeerCertificate: verifyPeerCertificate(pool, config.AllowedPeers)}, nil
+}
+
+// checks a peer's certificate chains to one of the CAs in the pool, when
+// there is a pool, and that its key is one of the allowed peers, when there
+// are any.  The handshake has already proved the peer holds that key.
+func verifyPeerCertificate(pool *x509.CertPool, allowedPeers []string) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("peer sent no certificate")
+		}
+		if len(allowedPeers) > 0 {
+			if id := peerIDOfCertificate(rawCerts[0]); !containsAddress(allowedPeers, id) {
+				return fmt.Errorf("peer %v is not one of network.allowed_peers", id)
+			}
+		}
+		if pool == nil {
+			return nil
+		}
+		certs := []*x509.Certificate{}
+		for _, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return err
+			}
+			certs = append(certs, cert)
+		}
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := certs[0].Verify(x509.VerifyOptions{Roots:         pool,
+		                                             Intermediates: intermediates,
+		                                             KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny}})
+		return err
+	}
+}
+
+// completes the TLS handshake of an accepted connection, which otherwise
+// happens on its first read or write, so the node only takes authenticated peers
+func handshake(conn net.Conn) error {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), handshakeTimeout)
+	defer cancel()
+	return tlsConn.HandshakeContext(ctx)
+}
+
+// nodes in one process listening on in-memory addresses such as memory:1999
+type memoryNetwork struct {
+	mu        sync.Mutex
+	listeners map[string]*memoryListener
+	nextPort  int // local port of the next dialed connection
+}
+
+func newMemoryNetwork() *memoryNetwork {
+	return &memoryNetwork{listeners: map[string]*memoryListener{}, nextPort: 49152}
+}
+
+// the network every memory transport configured by network.transport joins
+var sharedMemoryNetwork = newMemoryNetwork()
+
+type memoryTransport struct {
+	network *memoryNetwork
+}
+
+func (t memoryTransport) localHost() string {
+	return "memory"
+}
+
+func (t memoryTransport) listen(port string) (net.Listener, error) {
+	address := t.localHost() + port
+	t.network.mu.Lock()
+	defer t.network.mu.Unlock()
+	if _, taken := t.network.listeners[address]; taken {
+		return nil, fmt.Errorf("%v is already in use", address)
+	}
+	listener := &memoryListener{network: t.network,
+	                            address: memoryAddr(address),
+	                            conns:   make(chan net.Conn),
+	                            closed:  make(chan bool)}
+	t.network.listeners[address] = listener
+	return listener, nil
+}
+
+func (t memoryTransport) dial(address string) (net.Conn, error) {
+	t.network.mu.Lock()
+	listener, listening := t.network.listeners[address]
+	local := memoryAddr(fmt.Sprintf("%v:%v", t.localHost(), t.network.nextPort))
+	t.network.nextPort = t.network.nextPort + 1
+	t.network.mu.Unlock()
+	if !listening {
+		return nil, fmt.Errorf("dial %v: connection refused", address)
+	}
+
+	clientEnd, serverEnd := net.Pipe()
+	client := newMemoryConn(clientEnd, local, listener.address)
+	server := newMemoryConn(serverEnd, listener.address, local)
+	select {
+	case listener.conns <- server:
+		return client, nil
+	case <-listener.closed:
+		client.Close()
+		server.Close()
+		return nil, fmt.Errorf("dial %v: connection refused", address)
+	}
+}
+
+type memoryAddr string
+
+func (a memoryAddr) Network() string { return "memory" }
+func (a memoryAddr) String() string  { return string(a) }
+
+type memoryListener struct {
+	network *memoryNetwork
+	address memoryAddr
+	conns   chan net.Conn
+	closed  chan bool
+	once    sync.Once
+}
+
+func (l *memoryListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *memoryListener) Close() error {
+	l.once.Do(func() {
+		l.network.mu.Lock()
+		delete(l.network.listeners, string(l.address))
+		l.network.mu.Unlock()
+		close(l.closed)
+	})
+	return nil
+}
+
+func (l *memoryListener) Addr() net.Addr {
+	return l.address
+}
+
+// one end of an in-memory connection.  A net.Pipe write waits for the other
+// end to read it, so writes are queued and passed on by flush, buffering them
+// the way a TCP connection would; otherwise two nodes writing to each other
+// while their loops are busy would wait on each other forever.  Like TCP,
+// writes queued together reach the other end as one stream of bytes.
+type memoryConn struct {
+	net.Conn
+	local   memoryAddr
+	remote  memoryAddr
+	mu      sync.Mutex // guards closing the outbox
+	outbox  chan []byte
+	closing bool
+}
+
+func newMemoryConn(end net.Conn, local memoryAddr, remote memoryAddr) *memoryConn {
+	c := &memoryConn{Conn: end, local: local, remote: remote, outbox: make(chan []byte, 1024)}
+	go c.flush()
+	return c
+}
+
+func (c *memoryConn) LocalAddr() net.Addr  { return c.local }
+func (c *memoryConn) RemoteAddr() net.Addr { return c.remote }
+
+func (c *memoryConn) Write(buffer []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closing {
+		return 0, net.ErrClosed
+	}
+	c.outbox <- append([]byte{}, buffer...)
+	return len(buffer), nil
+}
+
+// passes queued writes to the pipe, closing it once the connection is closed and the queue is empty
+func (c *memoryConn) flush() {
+	for data := range c.outbox {
+		for len(c.outbox) > 0 {
+			data = append(data, <-c.outbox...)
+		}
+		if _, err := c.Conn.Write(data); err != nil {
+			break
+		}
+	}
+	c.Conn.Close()
+}
+
+// closes the connection once what was written has been passed on, as closing a TCP connection does
+func (c *memoryConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closing {
+		return nil
+	}
+	c.closing = true
+	close(c.outbox)
+	return nil
+}
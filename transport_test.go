@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewTransport(t *testing.T) {
+	identity, _ := loadIdentity("")
+	config := defaultConfig().Network
+	if transport, err := newTransport(config, identity); err != nil || transport.localHost() != getPrivateIP() {
+		t.Error("Default transport is not tcp")
+	}
+	config.Transport = "memory"
+	if transport, err := newTransport(config, identity); err != nil || transport.localHost() != "memory" {
+		t.Error("Memory transport was not picked")
+	}
+	config.Transport = "tls"
+	if transport, err := newTransport(config, identity); err != nil || transport.(tlsTransport).id != peerIDOfKey(identity.Public()) {
+		t.Error("Tls transport does not authenticate with the identity key")
+	}
+	config.Transport = "udp"
+	if _, err := newTransport(config, identity); err == nil {
+		t.Error("Picked an unknown transport")
+	}
+}
+
+// sends a line one way over a connection and back the other
+func exchange(t *testing.T, listener net.Listener, dial func(address string) (net.Conn, error)) error {
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			io.Copy(conn, conn) // echo
+		}
+		accepted <- conn
+	}()
+
+	conn, err := dial(listener.Addr().String())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		return err
+	}
+	echo := make([]byte, 5)
+	if _, err := io.ReadFull(conn, echo); err != nil {
+		return err
+	}
+	if string(echo) != "hello" {
+		t.Error("Echo was " + string(echo))
+	}
+	return nil
+}
+
+func TestMemoryTransport(t *testing.T) {
+	transport := memoryTransport{network: newMemoryNetwork()}
+	listener, err := transport.listen(":2000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if listener.Addr().String() != "memory:2000" {
+		t.Error("Listening at " + listener.Addr().String())
+	}
+	if _, err := transport.listen(":2000"); err == nil {
+		t.Error("Listened twice on the same address")
+	}
+
+	if err := exchange(t, listener, transport.dial); err != nil {
+		t.Error(err)
+	}
+	if _, err := transport.dial("memory:2001"); err == nil {
+		t.Error("Dialed an address nobody listens on")
+	}
+
+	listener.Close()
+	if _, err := listener.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Error("Closed listener still accepts")
+	}
+	if _, err := transport.dial("memory:2000"); err == nil {
+		t.Error("Dialed a closed listener")
+	}
+}
+
+func TestMemoryConnDeliversBeforeClosing(t *testing.T) {
+	transport := memoryTransport{network: newMemoryNetwork()}
+	listener, _ := transport.listen(":2000")
+	defer listener.Close()
+	go func() {
+		conn, _ := transport.dial("memory:2000")
+		conn.Write([]byte("goodbye"))
+		conn.Close()
+	}()
+
+	conn, _ := listener.Accept()
+	received, err := io.ReadAll(conn)
+	if err != nil || string(received) != "goodbye" {
+		t.Error("Data written before closing was lost:", string(received), err)
+	}
+}
+
+// writes a certificate for localhost signed by the CA, or self signed when ca is nil
+func writeTestCertificate(t *testing.T, dir string, name string, isCA bool, ca *x509.Certificate, caKey *ecdsa.PrivateKey) (string, string, *x509.Certificate, *ecdsa.PrivateKey) {
+	key, _   := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{SerialNumber:          big.NewInt(time.Now().UnixNano()),
+	                              Subject:               pkix.Name{CommonName: name},
+	                              NotBefore:             time.Now().Add(-time.Hour),
+	                              NotAfter:              time.Now().Add(time.Hour),
+	                              IsCA:                  isCA,
+	                              BasicConstraintsValid: true,
+	                              KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	                              ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}}
+	if ca == nil {
+		ca, caKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	certPath := filepath.Join(dir, name+".crt")
+	keyPath  := filepath.Join(dir, name+".key")
+	os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	return certPath, keyPath, cert, key
+}
+
+func TestTLSTransport(t *testing.T) {
+	dir := t.TempDir()
+	caPath, _, ca, caKey := writeTestCertificate(t, dir, "ca", true, nil, nil)
+	nodeCert, nodeKey, _, _ := writeTestCertificate(t, dir, "node", false, ca, caKey)
+	otherCert, otherKey, _, _ := writeTestCertificate(t, dir, "other", false, nil, nil)
+
+	config := defaultConfig().Network
+	config.Transport, config.TLSCert, config.TLSKey, config.TLSCA = "tls", nodeCert, nodeKey, caPath
+	transport, err := newTransport(config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := transport.listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	if err := exchange(t, listener, transport.dial); err != nil {
+		t.Error("Peers signed by the CA did not connect:", err)
+	}
+
+	// a peer whose certificate the CA did not sign is turned away
+	config.TLSCert, config.TLSKey = otherCert, otherKey
+	stranger, err := newTransport(config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := exchange(t, listener, stranger.dial); err == nil {
+		t.Error("Peer with a certificate from another CA connected")
+	}
+
+	// without a CA, connections are encrypted but anyone may connect
+	config.TLSCA = ""
+	unchecked, _ := newTransport(config, nil)
+	uncheckedListener, err := unchecked.listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer uncheckedListener.Close()
+	if err := exchange(t, uncheckedListener, unchecked.dial); err != nil {
+		t.Error("Peers did not connect without a CA:", err)
+	}
+}
+
+func TestNodesOverMemoryTransport(t *testing.T) {
+	useDifficulty(t, 4294967295) // all hashes pass
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	nodes   := []*Node{}
+	stopped := []chan bool{}
+	for i, port := range []string{":2201", ":2202"} {
+		n := newNode()
+		n.config.Network.Transport = "memory"
+		done := make(chan bool)
+		seeds := []string{}
+		if i > 0 {
+			seeds = append(seeds, "2201")
+		}
+		go func() {
+			n.run(ctx, port, seeds, false)
+			close(done)
+		}()
+		if !waitFor(2*time.Second, func() bool { return n.getAddress() != "" }) {
+			t.Fatal("node did not start")
+		}
+		nodes   = append(nodes, n)
+		stopped = append(stopped, done)
+	}
+	if nodes[1].getAddress() != "memory:2202" {
+		t.Error("Node is not at its memory address: " + nodes[1].getAddress())
+	}
+	if !waitFor(5*time.Second, func() bool { return len(nodes[0].getRemoteAddresses()) == 1 }) {
+		t.Fatal("node did not connect to its seed over the memory transport")
+	}
+
+	block := Block{Index: 1, PrevHash: genesisBlock.Hash, Data: genesisBlock.Data, Records: []Record{}}
+	block.Hash = block.calcHashForBlock(0)
+	nodes[1].blockWrapperChannel <- &BlockWrapper{Block: block, Sender: nodes[1].getAddress()}
+	if !waitFor(5*time.Second, func() bool { return nodes[0].getBlockchain().getLastBlock().Index == 1 }) {
+		t.Error("block did not reach the seed over the memory transport")
+	}
+
+	cancel()
+	for _, done := range stopped {
+		<-done
+	}
+}
+
+func TestTLSTransportAuthenticatesIdentities(t *testing.T) {
+	serverIdentity, _   := loadIdentity("")
+	clientIdentity, _   := loadIdentity("")
+	strangerIdentity, _ := loadIdentity("")
+
+	config := defaultConfig().Network
+	config.Transport    = "tls"
+	config.AllowedPeers = []string{peerIDOfKey(serverIdentity.Public()), peerIDOfKey(clientIdentity.Public())}
+	server, _   := newTransport(config, serverIdentity)
+	client, _   := newTransport(config, clientIdentity)
+	stranger, _ := newTransport(config, strangerIdentity)
+
+	listener, err := server.listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// each side learns the other's ID from the handshake
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, _ := listener.Accept()
+		if handshake(conn) != nil {
+			conn.Close()
+		}
+		accepted <- conn
+	}()
+	conn, err := client.dial(listener.Addr().String())
+	if err != nil {
+		t.Fatal("Allowed peer did not connect:", err)
+	}
+	serverConn := <-accepted
+	if peerID(conn) != peerIDOfKey(serverIdentity.Public()) || peerID(serverConn) != peerIDOfKey(clientIdentity.Public()) {
+		t.Error("Peers do not know each other by their identity keys")
+	}
+	conn.Close()
+	serverConn.Close()
+
+	// a peer that is not allowed fails the handshake
+	go func() {
+		conn, _ := listener.Accept()
+		accepted <- conn
+		if handshake(conn) == nil {
+			t.Error("Server accepted a peer that is not allowed")
+		}
+		conn.Close()
+	}()
+	conn, err = stranger.dial(listener.Addr().String())
+	if err == nil { // with TLS 1.3 the client only hears it was turned away on its first read
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		_, err = conn.Read(make([]byte, 1))
+		conn.Close()
+	}
+	if err == nil {
+		t.Error("Peer that is not allowed connected")
+	}
+	<-accepted
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+/*
+tui.go is the full screen console started with --tui.  Panes show the
+chain tip, peers, mempool, miner and the latest logs, and are redrawn
+as the node changes.  Commands are typed on the bottom line, with
+history on the up and down keys and tab completion of command names.
+
+The commands in control.go read stdin and print to stdout, asking
+questions over several lines, so rather than rewrite them the TUI
+swaps stdin and stdout for pipes: lines entered on the command line are
+written to stdin, and everything printed to stdout fills the output
+pane.  tcell draws on the terminal itself, so it is not affected.
+*/
+
+const tuiRefreshInterval = 500 * time.Millisecond
+const tuiRecentBlocks    = 10
+const tuiMaxLines        = 1000
+
+type nodeTUI struct {
+	app      *tview.Application
+	chain    *tview.TextView
+	peers    *tview.TextView
+	mempool  *tview.TextView
+	miner    *tview.TextView
+	output   *tview.TextView
+	logs     *tview.TextView
+	input    *tview.InputField
+	history  commandHistory
+	commands io.Writer // read by control.go as stdin
+}
+
+// builds the screen and takes over stdin, stdout and the logs; call before starting the node
+func newNodeTUI(logging LoggingConfig, logFile *os.File) (*nodeTUI, error) {
+	t := &nodeTUI{app: tview.NewApplication()}
+	t.chain   = t.newPane(" chain ")
+	t.peers   = t.newPane(" peers ")
+	t.mempool = t.newPane(" mempool ")
+	t.miner   = t.newPane(" miner ")
+	t.output  = t.newPane(" output ")
+	t.logs    = t.newPane(" logs ")
+	t.output.SetScrollable(true).SetMaxLines(tuiMaxLines).SetChangedFunc(func() { t.app.Draw() })
+	t.logs.SetScrollable(true).SetMaxLines(tuiMaxLines).SetChangedFunc(func() { t.app.Draw() })
+
+	t.input = tview.NewInputField().SetLabel("> ").SetFieldBackgroundColor(tcell.ColorDefault)
+	t.input.SetDoneFunc(t.enterCommand)
+	t.input.SetInputCapture(t.commandKey)
+
+	status := tview.NewFlex().
+		AddItem(t.chain, 0, 2, false).
+		AddItem(t.peers, 0, 1, false).
+		AddItem(t.mempool, 0, 1, false).
+		AddItem(t.miner, 0, 1, false)
+	consoles := tview.NewFlex().
+		AddItem(t.output, 0, 1, false).
+		AddItem(t.logs, 0, 1, false)
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(status, tuiRecentBlocks+3, 0, false).
+		AddItem(consoles, 0, 1, false).
+		AddItem(t.input, 1, 0, true)
+	t.app.SetRoot(layout, true).SetFocus(t.input)
+
+	stdinReader, stdinWriter, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	stdoutReader, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	os.Stdin   = stdinReader
+	os.Stdout  = stdoutWriter
+	t.commands = stdinWriter
+	go io.Copy(t.output, stdoutReader)
+
+	var logOutput io.Writer = t.logs
+	if logFile != nil {
+		logOutput = io.MultiWriter(t.logs, logFile)
+	}
+	setLogger(newLogger(logOutput, logging))
+	return t, nil
+}
+
+func (t *nodeTUI) newPane(title string) *tview.TextView {
+	pane := tview.NewTextView()
+	pane.SetBorder(true).SetTitle(title).SetTitleAlign(tview.AlignLeft)
+	return pane
+}
+
+// shows the node until it stops; ctrl-c asks the node to stop, as quit does
+func (t *nodeTUI) run(n *Node, stop context.CancelFunc, stopped chan bool) error {
+	t.app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyCtrlC {
+			stop()
+			return nil
+		}
+		return event
+	})
+
+	go func() {
+		ticker := time.NewTicker(tuiRefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopped:
+				t.app.Stop()
+				return
+			case <-ticker.C:
+				t.app.QueueUpdateDraw(func() { t.refresh(n) })
+			}
+		}
+	}()
+	return t.app.Run()
+}
+
+func (t *nodeTUI) refresh(n *Node) {
+	t.chain.SetText(formatChainPane(n.getBlockchain(), tuiRecentBlocks))
+	t.peers.SetText(formatPeersPane(n.getAddress(), n.getSeed(), n.getRemoteAddresses()))
+	t.mempool.SetText(formatMempoolPane(n.getCurPacketList(), n.getCurRecordList()))
+	t.miner.SetText(formatMinerPane(runningMiners.Load(), hashRate.get(), hashesTried.get()))
+}
+
+func (t *nodeTUI) enterCommand(key tcell.Key) {
+	if key != tcell.KeyEnter {
+		return
+	}
+	command := t.input.GetText()
+	t.input.SetText("")
+	if strings.TrimSpace(command) == "" { // an empty line would stop listenForUserInput
+		return
+	}
+	t.history.add(command)
+	fmt.Fprintf(t.output, "> %v\n", command)
+	t.commands.Write([]byte(command + "\n"))
+}
+
+func (t *nodeTUI) commandKey(event *tcell.EventKey) *tcell.EventKey {
+	switch event.Key() {
+	case tcell.KeyUp:
+		if command, found := t.history.previous(); found {
+			t.input.SetText(command)
+		}
+		return nil
+	case tcell.KeyDown:
+		if command, found := t.history.next(); found {
+			t.input.SetText(command)
+		}
+		return nil
+	case tcell.KeyTab:
+		completed, matches := completeCommand(t.input.GetText(), nodeCommands)
+		t.input.SetText(completed)
+		if len(matches) > 1 {
+			fmt.Fprintln(t.output, strings.Join(matches, "  "))
+		}
+		return nil
+	}
+	return event
+}
+
+// the commands entered so far; position is len(entries) while a new command is typed
+type commandHistory struct {
+	entries  []string
+	position int
+}
+
+func (h *commandHistory) add(command string) {
+	if len(h.entries) == 0 || h.entries[len(h.entries)-1] != command {
+		h.entries = append(h.entries, command)
+	}
+	h.position = len(h.entries)
+}
+
+func (h *commandHistory) previous() (string, bool) {
+	if h.position == 0 {
+		return "", false
+	}
+	h.position = h.position - 1
+	return h.entries[h.position], true
+}
+
+// the next command, or an empty line once past the last one
+func (h *commandHistory) next() (string, bool) {
+	if h.position >= len(h.entries) {
+		return "", false
+	}
+	h.position = h.position + 1
+	if h.position == len(h.entries) {
+		return "", true
+	}
+	return h.entries[h.position], true
+}
+
+// completes the command name being typed, as far as every matching command agrees
+func completeCommand(text string, commands []string) (string, []string) {
+	if strings.Contains(text, " ") {
+		return text, nil
+	}
+	matches := []string{}
+	for _, command := range commands {
+		if strings.HasPrefix(command, strings.ToLower(text)) {
+			matches = append(matches, command)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return text, matches
+	case 1:
+		return matches[0] + " ", matches
+	}
+	common := matches[0]
+	for _, match := range matches[1:] {
+		for !strings.HasPrefix(match, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	return common, matches
+}
+
+func formatChainPane(blockchain Blockchain, recent int) string {
+	text := fmt.Sprintf("height %v\n", blockchain.getLastBlock().Index)
+	for i := len(blockchain.Blocks) - 1; i >= 0 && i >= len(blockchain.Blocks)-recent; i-- {
+		block := blockchain.Blocks[i]
+		text   = text + fmt.Sprintf("#%-5v %.16x  %v packets, %v records\n", block.Index, block.Hash, len(block.Data), len(block.Records))
+	}
+	return text
+}
+
+func formatPeersPane(address string, seed string, remoteAddresses []string) string {
+	text := fmt.Sprintf("you  %v\nseed %v\n%v connected\n", address, seed, len(remoteAddresses))
+	for _, remoteAddress := range remoteAddresses {
+		text = text + remoteAddress + "\n"
+	}
+	return text
+}
+
+func formatMempoolPane(packets []Packet, records []Record) string {
+	text := fmt.Sprintf("%v packets\n", len(packets))
+	for _, packet := range packets {
+		text = text + fmt.Sprintf(" %.16x\n", packet.Hash)
+	}
+	return text + fmt.Sprintf("%v records\n", len(records))
+}
+
+func formatMinerPane(miners int32, rate float64, hashes float64) string {
+	status := "idle"
+	if miners > 0 {
+		status = "mining"
+	}
+	return fmt.Sprintf("%v\n%.0f hashes/s\n%.0f hashes tried\n", status, rate, hashes)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompleteCommand(t *testing.T) {
+	commands := []string{"getchain", "getconns", "genkeys", "quit"}
+
+	completed, matches := completeCommand("q", commands)
+	if completed != "quit " || len(matches) != 1 {
+		t.Error("Single match was not completed:", completed, matches)
+	}
+	completed, matches = completeCommand("getc", commands)
+	if completed != "getc" || !reflect.DeepEqual(matches, []string{"getchain", "getconns"}) {
+		t.Error("Matches were not listed:", completed, matches)
+	}
+	completed, _ = completeCommand("ge", commands)
+	if completed != "ge" {
+		t.Error("Completed past where the matches disagree:", completed)
+	}
+	completed, _ = completeCommand("get", []string{"getchain", "getconns"})
+	if completed != "getc" {
+		t.Error("Common prefix of the matches was not completed:", completed)
+	}
+	completed, matches = completeCommand("x", commands)
+	if completed != "x" || len(matches) != 0 {
+		t.Error("Unknown command was changed:", completed, matches)
+	}
+	completed, _ = completeCommand("config d", commands)
+	if completed != "config d" {
+		t.Error("Arguments were completed as a command:", completed)
+	}
+}
+
+func TestCommandHistory(t *testing.T) {
+	h := commandHistory{}
+	if _, found := h.previous(); found {
+		t.Error("Empty history returned a command")
+	}
+	h.add("getconns")
+	h.add("getchain")
+	h.add("getchain")
+
+	if command, _ := h.previous(); command != "getchain" {
+		t.Error("Expected the last command, got", command)
+	}
+	if command, _ := h.previous(); command != "getconns" {
+		t.Error("Repeated command was kept twice, got", command)
+	}
+	if _, found := h.previous(); found {
+		t.Error("Went back past the first command")
+	}
+	if command, _ := h.next(); command != "getchain" {
+		t.Error("Expected the next command, got", command)
+	}
+	if command, found := h.next(); !found || command != "" {
+		t.Error("Expected an empty line after the last command, got", command)
+	}
+	if _, found := h.next(); found {
+		t.Error("Went forward past the empty line")
+	}
+}
+
+func TestFormatPanes(t *testing.T) {
+	blockchain := Blockchain{}
+	for i := 0; i < 5; i++ {
+		blockchain.Blocks = append(blockchain.Blocks, Block{Index: uint32(i), Hash: []byte{byte(i)}})
+	}
+	chain := formatChainPane(blockchain, 3)
+	if !strings.HasPrefix(chain, "height 4\n#4") || strings.Count(chain, "\n") != 4 || strings.Contains(chain, "#1 ") {
+		t.Error("Chain pane does not show the most recent blocks:\n" + chain)
+	}
+
+	peers := formatPeersPane("127.0.0.1:1999", "127.0.0.1:2000", []string{"127.0.0.1:2000", "127.0.0.1:2001"})
+	if !strings.Contains(peers, "2 connected\n127.0.0.1:2000\n127.0.0.1:2001\n") {
+		t.Error("Peers pane does not list the peers:\n" + peers)
+	}
+
+	mempool := formatMempoolPane([]Packet{{Hash: []byte{0xab}}}, nil)
+	if mempool != "1 packets\n ab\n0 records\n" {
+		t.Error("Mempool pane is wrong:\n" + mempool)
+	}
+
+	if miner := formatMinerPane(1, 1500, 30000); miner != "mining\n1500 hashes/s\n30000 hashes tried\n" {
+		t.Error("Miner pane is wrong:\n" + miner)
+	}
+	if miner := formatMinerPane(0, 0, 0); !strings.HasPrefix(miner, "idle\n") {
+		t.Error("Stopped miner is not idle:\n" + miner)
+	}
+}
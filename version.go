@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net"
+)
+
+/*
+version.go is the handshake two nodes exchange when they connect.  Each
+side tells the other the address it sees the connection coming from,
+which is how a public node finds the address it is reachable at without
+asking a third party.  One peer could lie or sit behind the same NAT, so
+the node only adopts an address once a majority of its peers agree on
+it.  An address given with --external-addr is always used instead.
+
+The version must be the first communication on a connection, and a node
+closes a connection whose peer speaks another protocol version, or none.
+The version is raised whenever nodes of different versions would not
+agree on blocks.
+*/
+
+// 2: blocks hash the merkle root of their packets and a hash of their
+// records, so every block hash, the genesis block's too, differs from version 1
+const protocolVersion = 2
+
+type Version struct {
+	Protocol        uint32
+	ObservedAddress string // the IP the sender sees the receiver's connection coming from
+}
+
+// a version received on a connection, for the node's loop
+type versionMessage struct {
+	conn    net.Conn
+	version Version
+}
+
+func newVersion(conn net.Conn) Version {
+	observed, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		observed = ""
+	}
+	return Version{Protocol: protocolVersion, ObservedAddress: observed}
+}
+
+// whether the first communication on a connection is a version this node speaks
+func isCompatibleVersion(communication Communication) bool {
+	return communication.ID == 8 && communication.Version.Protocol == protocolVersion
+}
+
+func sendVersion(conn net.Conn) {
+	communication := Communication{ID: 8, Version: newVersion(conn)}
+	writeCommunication(conn, communication)
+}
+
+// the IP reported by more than half of the hosts that voted, if any.  Each
+// host has one vote however many connections it opens, so one peer can not
+// pick the address, and a host reporting two addresses has no say.
+func majorityAddress(votes map[net.Conn]string) (string, bool) {
+	hostVotes := map[string]string{}
+	for conn, address := range votes {
+		host := remoteHost(conn)
+		if voted, seen := hostVotes[host]; seen && voted != address {
+			address = ""
+		}
+		hostVotes[host] = address
+	}
+	counts := map[string]int{}
+	for _, address := range hostVotes {
+		if address != "" {
+			counts[address] = counts[address] + 1
+		}
+	}
+	for address, count := range counts {
+		if count*2 > len(hostVotes) {
+			return address, true
+		}
+	}
+	return "", false
+}
+
+// the IP a connection comes from, or its whole address if it has none
+func remoteHost(conn net.Conn) string {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return conn.RemoteAddr().String()
+	}
+	return host
+}
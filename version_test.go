@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNewVersion(t *testing.T) {
+	connIn, connOut := net.Pipe()
+	defer connIn.Close()
+	defer connOut.Close()
+
+	// net.Pipe has no IP addresses, so there is nothing to report
+	if newVersion(connIn).ObservedAddress != "" || newVersion(connIn).Protocol != protocolVersion {
+		t.Error("newVersion should report no address for a connection without one")
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if newVersion(conn).ObservedAddress != "127.0.0.1" {
+		t.Error("newVersion should report the IP of the other end of the connection")
+	}
+}
+
+// a connection from the given address, for counting votes
+type remoteConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+func (c remoteConn) RemoteAddr() net.Addr { return c.remote }
+
+func TestMajorityAddress(t *testing.T) {
+	conns := []net.Conn{}
+	for i := 0; i < 4; i++ {
+		conns = append(conns, remoteConn{remote: &net.TCPAddr{IP: net.IPv4(192, 0, 2, byte(i+1)), Port: 1999}})
+	}
+
+	if _, found := majorityAddress(map[net.Conn]string{}); found {
+		t.Error("majorityAddress found an address without votes")
+	}
+
+	votes := map[net.Conn]string{conns[0]: "203.0.113.7", conns[1]: "203.0.113.7", conns[2]: "198.51.100.1"}
+	if address, found := majorityAddress(votes); !found || address != "203.0.113.7" {
+		t.Error("majorityAddress should pick the address most peers agree on")
+	}
+
+	votes[conns[3]] = "198.51.100.1"
+	if _, found := majorityAddress(votes); found {
+		t.Error("majorityAddress should not pick an address only half the peers report")
+	}
+
+	// one host opening more connections still has one vote
+	votes = map[net.Conn]string{conns[0]: "203.0.113.7", conns[1]: "203.0.113.7"}
+	for port := 2000; port < 2005; port++ {
+		votes[remoteConn{remote: &net.TCPAddr{IP: net.IPv4(192, 0, 2, 3), Port: port}}] = "198.51.100.1"
+	}
+	if address, found := majorityAddress(votes); !found || address != "203.0.113.7" {
+		t.Error("majorityAddress let one host outvote the others with several connections")
+	}
+}
+
+func TestIncompatiblePeersAreDisconnected(t *testing.T) {
+	for name, first := range map[string]Communication{"an older version": {ID: 8, Version: Version{Protocol: protocolVersion - 1}},
+	                                                  "no version":       {ID: 2}} {
+		n := newNode()
+		connIn, connOut := net.Pipe()
+		go n.listenToConn(connIn)
+		go writeCommunication(connOut, first)
+
+		select {
+		case conn := <-n.disconChannel:
+			if conn != connIn {
+				t.Error("Another connection was closed")
+			}
+		case <-n.connRequestChannel:
+			t.Error("Peer speaking " + name + " was listened to")
+		case <-time.After(5 * time.Second):
+			t.Error("Peer speaking " + name + " was not disconnected")
+		}
+		connOut.Close()
+	}
+}